@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -18,6 +19,10 @@ import (
 	"time"
 )
 
+// ErrJWKSUnavailable is wrapped by token validation errors caused by fetching the signing keys, the
+// token itself may be valid
+var ErrJWKSUnavailable = errors.New("failed to get JWKS")
+
 // BaseOAuthClient provides common OAuth functionality
 type BaseOAuthClient struct {
 	ClientId     string
@@ -45,6 +50,29 @@ func NewBaseOAuthClient(clientId, clientSecret, tokenUrl, configUrl string) *Bas
 	}
 }
 
+// SetTransport replaces the transport of the HTTP client, e.g. with a gravel http.RequestIDTransport
+// so the request id of the context passed to the *Context methods is forwarded
+func (client *BaseOAuthClient) SetTransport(transport http.RoundTripper) {
+	client.httpClient.Transport = transport
+}
+
+func (client *BaseOAuthClient) get(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.httpClient.Do(req)
+}
+
+func (client *BaseOAuthClient) postForm(ctx context.Context, target string, values url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.httpClient.Do(req)
+}
+
 // SetScopes sets custom scopes for the OAuth client
 func (client *BaseOAuthClient) SetScopes(scopes []string) {
 	client.Scopes = scopes
@@ -108,6 +136,10 @@ func NewAuth0Client(clientId, clientSecret, domain, audience string) *Auth0Clien
 
 // GetOpenIDConfiguration fetches the OpenID Connect configuration
 func (client *BaseOAuthClient) GetOpenIDConfiguration() (*OpenIDConfiguration, error) {
+	return client.GetOpenIDConfigurationContext(context.Background())
+}
+
+func (client *BaseOAuthClient) GetOpenIDConfigurationContext(ctx context.Context) (*OpenIDConfiguration, error) {
 	client.cacheMutex.RLock()
 	if client.configCache != nil && time.Now().Before(client.cacheExpiry) {
 		defer client.cacheMutex.RUnlock()
@@ -115,7 +147,7 @@ func (client *BaseOAuthClient) GetOpenIDConfiguration() (*OpenIDConfiguration, e
 	}
 	client.cacheMutex.RUnlock()
 
-	resp, err := client.httpClient.Get(client.ConfigUrl)
+	resp, err := client.get(ctx, client.ConfigUrl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch OpenID configuration: %w", err)
 	}
@@ -146,7 +178,11 @@ func (client *BaseOAuthClient) GetOpenIDConfiguration() (*OpenIDConfiguration, e
 
 // GetJWKS fetches the JSON Web Key Set for token validation
 func (client *BaseOAuthClient) GetJWKS() (*JWKSet, error) {
-	config, err := client.GetOpenIDConfiguration()
+	return client.GetJWKSContext(context.Background())
+}
+
+func (client *BaseOAuthClient) GetJWKSContext(ctx context.Context) (*JWKSet, error) {
+	config, err := client.GetOpenIDConfigurationContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +194,7 @@ func (client *BaseOAuthClient) GetJWKS() (*JWKSet, error) {
 	}
 	client.cacheMutex.RUnlock()
 
-	resp, err := client.httpClient.Get(config.JwksURI)
+	resp, err := client.get(ctx, config.JwksURI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
 	}
@@ -277,6 +313,10 @@ func (client *BaseOAuthClient) ValidateTokenWithoutSignature(tokenString string)
 
 // ValidateToken validates a JWT token using the JWKS
 func (client *BaseOAuthClient) ValidateToken(tokenString string) (*JWTPayload, error) {
+	return client.ValidateTokenContext(context.Background(), tokenString)
+}
+
+func (client *BaseOAuthClient) ValidateTokenContext(ctx context.Context, tokenString string) (*JWTPayload, error) {
 	// Parse JWT token
 	parts := strings.Split(tokenString, ".")
 	if len(parts) != 3 {
@@ -316,9 +356,9 @@ func (client *BaseOAuthClient) ValidateToken(tokenString string) (*JWTPayload, e
 	}
 
 	// Get JWKS for signature validation
-	jwks, err := client.GetJWKS()
+	jwks, err := client.GetJWKSContext(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get JWKS: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
 	}
 
 	// Find matching key
@@ -376,7 +416,11 @@ func (client *BaseOAuthClient) GetTokenClaims(tokenString string) (*JWTPayload,
 
 // Microsoft Token implementation
 func (client *MicrosoftClient) Token() (*TokenResponse, error) {
-	response, err := client.httpClient.PostForm(client.TokenUrl, url.Values{
+	return client.TokenContext(context.Background())
+}
+
+func (client *MicrosoftClient) TokenContext(ctx context.Context) (*TokenResponse, error) {
+	response, err := client.postForm(ctx, client.TokenUrl, url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {client.ClientId},
 		"client_secret": {client.ClientSecret},
@@ -426,7 +470,11 @@ func (client *MicrosoftClient) TokenString() (string, error) {
 
 // RefreshToken refreshes an access token using a refresh token
 func (client *MicrosoftClient) RefreshToken(refreshToken string) (*TokenResponse, error) {
-	response, err := client.httpClient.PostForm(client.TokenUrl, url.Values{
+	return client.RefreshTokenContext(context.Background(), refreshToken)
+}
+
+func (client *MicrosoftClient) RefreshTokenContext(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	response, err := client.postForm(ctx, client.TokenUrl, url.Values{
 		"grant_type":    {"refresh_token"},
 		"client_id":     {client.ClientId},
 		"client_secret": {client.ClientSecret},
@@ -473,7 +521,11 @@ func (client *MicrosoftClient) RefreshToken(refreshToken string) (*TokenResponse
 
 // Google Token implementation
 func (client *GoogleClient) Token() (*TokenResponse, error) {
-	response, err := client.httpClient.PostForm(client.TokenUrl, url.Values{
+	return client.TokenContext(context.Background())
+}
+
+func (client *GoogleClient) TokenContext(ctx context.Context) (*TokenResponse, error) {
+	response, err := client.postForm(ctx, client.TokenUrl, url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {client.ClientId},
 		"client_secret": {client.ClientSecret},
@@ -523,7 +575,11 @@ func (client *GoogleClient) TokenString() (string, error) {
 
 // RefreshToken refreshes an access token using a refresh token
 func (client *GoogleClient) RefreshToken(refreshToken string) (*TokenResponse, error) {
-	response, err := client.httpClient.PostForm(client.TokenUrl, url.Values{
+	return client.RefreshTokenContext(context.Background(), refreshToken)
+}
+
+func (client *GoogleClient) RefreshTokenContext(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	response, err := client.postForm(ctx, client.TokenUrl, url.Values{
 		"grant_type":    {"refresh_token"},
 		"client_id":     {client.ClientId},
 		"client_secret": {client.ClientSecret},
@@ -570,6 +626,10 @@ func (client *GoogleClient) RefreshToken(refreshToken string) (*TokenResponse, e
 
 // Auth0 Token implementation
 func (client *Auth0Client) Token() (*TokenResponse, error) {
+	return client.TokenContext(context.Background())
+}
+
+func (client *Auth0Client) TokenContext(ctx context.Context) (*TokenResponse, error) {
 	values := url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {client.ClientId},
@@ -581,7 +641,7 @@ func (client *Auth0Client) Token() (*TokenResponse, error) {
 		values.Set("audience", client.Audience)
 	}
 
-	response, err := client.httpClient.PostForm(client.TokenUrl, values)
+	response, err := client.postForm(ctx, client.TokenUrl, values)
 	if response != nil {
 		defer func() {
 			if closeErr := response.Body.Close(); closeErr != nil {
@@ -626,6 +686,10 @@ func (client *Auth0Client) TokenString() (string, error) {
 
 // RefreshToken refreshes an access token using a refresh token
 func (client *Auth0Client) RefreshToken(refreshToken string) (*TokenResponse, error) {
+	return client.RefreshTokenContext(context.Background(), refreshToken)
+}
+
+func (client *Auth0Client) RefreshTokenContext(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	values := url.Values{
 		"grant_type":    {"refresh_token"},
 		"client_id":     {client.ClientId},
@@ -633,7 +697,7 @@ func (client *Auth0Client) RefreshToken(refreshToken string) (*TokenResponse, er
 		"refresh_token": {refreshToken},
 	}
 
-	response, err := client.httpClient.PostForm(client.TokenUrl, values)
+	response, err := client.postForm(ctx, client.TokenUrl, values)
 	if response != nil {
 		defer func() {
 			if closeErr := response.Body.Close(); closeErr != nil {
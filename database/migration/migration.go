@@ -75,6 +75,23 @@ type Migrator interface {
 	Verify(ctx context.Context) error
 }
 
+// Pinger is implemented by migrators which can reach their database, e.g. the one of NewMigrator
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthCheck returns a check reporting whether the database of the migrator is reachable
+func HealthCheck(migrator Migrator) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if pinger, ok := migrator.(Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+
+		// Nothing to check for migrators without a connection of their own
+		return nil
+	}
+}
+
 type MigratorConfig struct {
 	LockTimeout     time.Duration
 	AutoRollback    bool // Rollback on failure
@@ -474,6 +491,11 @@ func (m *migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
 	return statuses, nil
 }
 
+// Ping checks the database connection, usable as health check
+func (m *migrator) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
 func (m *migrator) CurrentVersion(ctx context.Context) (string, error) {
 	if err := m.ensureMigrationTable(ctx); err != nil {
 		return "", fmt.Errorf("failed to create migration table: %w", err)
@@ -0,0 +1,234 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+
+	DefaultTimeout  = 2 * time.Second
+	DefaultCacheTTL = time.Second
+)
+
+var ErrShuttingDown = errors.New("health: server is shutting down")
+
+// ShutdownCheckName is the readiness check reported while shutting down, it cannot be registered
+const ShutdownCheckName = "shutdown"
+
+// Check reports an unhealthy dependency by returning an error, it must respect ctx cancellation
+type Check func(ctx context.Context) error
+
+type CheckOptions struct {
+	// Maximum duration of a single run, defaults to DefaultTimeout
+	Timeout time.Duration
+
+	// How long a result is reused between probes, defaults to DefaultCacheTTL (negative disables caching)
+	CacheTTL time.Duration
+}
+
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type registeredCheck struct {
+	name    string
+	check   Check
+	options CheckOptions
+
+	mu        sync.Mutex
+	result    CheckResult
+	expiresAt time.Time
+}
+
+// Health keeps separate liveness and readiness checks, e.g. for Kubernetes probes
+type Health struct {
+	mu        sync.RWMutex
+	liveness  []*registeredCheck
+	readiness []*registeredCheck
+
+	shuttingDown atomic.Bool
+}
+
+func New() *Health {
+	return &Health{}
+}
+
+// AddLivenessCheck registers a check which restarts the process when failing, keep it local (e.g. deadlocks)
+func (h *Health) AddLivenessCheck(name string, check Check, options CheckOptions) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.liveness = append(h.liveness, newRegisteredCheck(name, check, options))
+}
+
+// AddReadinessCheck registers a check which stops traffic when failing, e.g. a database ping
+func (h *Health) AddReadinessCheck(name string, check Check, options CheckOptions) {
+	if name == ShutdownCheckName {
+		panic("health: readiness check name " + ShutdownCheckName + " is reserved")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.readiness = append(h.readiness, newRegisteredCheck(name, check, options))
+}
+
+// Attach fails readiness as soon as the server starts shutting down. Traffic is only drained when
+// server.ShutdownDelay exceeds the probe period, otherwise the listeners close before load balancers
+// notice. Without Attach call MarkShuttingDown, wait, then Shutdown
+func (h *Health) Attach(server *http.Server) {
+	server.RegisterOnShutdown(h.MarkShuttingDown)
+}
+
+func (h *Health) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Health) Liveness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.liveness
+	h.mu.RUnlock()
+
+	return runChecks(ctx, checks)
+}
+
+func (h *Health) Readiness(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.readiness
+	h.mu.RUnlock()
+
+	report := runChecks(ctx, checks)
+	if h.shuttingDown.Load() {
+		report.Status = StatusFail
+		report.Checks[ShutdownCheckName] = CheckResult{
+			Status:    StatusFail,
+			Error:     ErrShuttingDown.Error(),
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		}
+	}
+	return report
+}
+
+func (h *Health) LivenessHandler() http.Handler {
+	return func(req *http.Request, res *http.Response) {
+		writeReport(res, h.Liveness(req.Context()))
+	}
+}
+
+func (h *Health) ReadinessHandler() http.Handler {
+	return func(req *http.Request, res *http.Response) {
+		writeReport(res, h.Readiness(req.Context()))
+	}
+}
+
+func writeReport(res *http.Response, report Report) {
+	if report.Status != StatusPass {
+		res.Status = http.StatusServiceUnavailable
+	}
+	res.SetHeaderString("Cache-Control", "no-store")
+	res.WithJSON(report)
+}
+
+func newRegisteredCheck(name string, check Check, options CheckOptions) *registeredCheck {
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.CacheTTL == 0 {
+		options.CacheTTL = DefaultCacheTTL
+	}
+
+	return &registeredCheck{
+		name:    name,
+		check:   check,
+		options: options,
+	}
+}
+
+// runChecks runs all checks concurrently, so the slowest check bounds the probe duration
+func runChecks(ctx context.Context, checks []*registeredCheck) Report {
+	report := Report{
+		Status: StatusPass,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusPass {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *registeredCheck) run(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.expiresAt) {
+		return c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.options.Timeout)
+	defer cancel()
+
+	// Run in a goroutine so a check ignoring ctx cannot block the probe
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- fmt.Errorf("health: check panicked: %v", recovered)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.result = CheckResult{
+		Status:    StatusPass,
+		Duration:  time.Since(now).String(),
+		CheckedAt: now,
+	}
+	if err != nil {
+		c.result.Status = StatusFail
+		c.result.Error = err.Error()
+	}
+	c.expiresAt = now.Add(c.options.CacheTTL)
+
+	return c.result
+}
@@ -0,0 +1,140 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/freekieb7/gravel/health"
+	"github.com/freekieb7/gravel/net/http"
+)
+
+func TestReadiness(t *testing.T) {
+	var calls atomic.Int32
+	h := health.New()
+	h.AddReadinessCheck("database", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}, health.CheckOptions{CacheTTL: time.Minute})
+	h.AddReadinessCheck("cache", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}, health.CheckOptions{})
+
+	report := h.Readiness(context.Background())
+	if report.Status != health.StatusFail {
+		t.Errorf("Expected failing readiness, got %s", report.Status)
+	}
+	if report.Checks["database"].Status != health.StatusPass || report.Checks["cache"].Error != "connection refused" {
+		t.Errorf("Unexpected checks %+v", report.Checks)
+	}
+
+	// Results are cached
+	h.Readiness(context.Background())
+	if calls.Load() != 1 {
+		t.Errorf("Expected cached result, check ran %d times", calls.Load())
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	h := health.New()
+	h.AddLivenessCheck("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, health.CheckOptions{Timeout: 10 * time.Millisecond})
+
+	start := time.Now()
+	report := h.Liveness(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Expected check to be abandoned after its timeout")
+	}
+	if report.Checks["stuck"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected deadline exceeded, got %+v", report.Checks["stuck"])
+	}
+}
+
+func TestReadinessFailsOnShutdown(t *testing.T) {
+	h := health.New()
+	server := http.NewServer(func(req *http.Request, res *http.Response) {})
+	h.Attach(&server)
+
+	res := &http.Response{}
+	res.Reset()
+	h.ReadinessHandler()(&http.Request{}, res)
+	if res.Status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.Status)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	res.Reset()
+	h.ReadinessHandler()(&http.Request{}, res)
+	if res.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", res.Status)
+	}
+
+	var report health.Report
+	if err := json.Unmarshal(res.Body, &report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.Checks[health.ShutdownCheckName].Error, "shutting down") {
+		t.Errorf("Expected shutdown check, got %+v", report.Checks)
+	}
+
+	// Liveness is unaffected
+	res.Reset()
+	h.LivenessHandler()(&http.Request{}, res)
+	if res.Status != http.StatusOK {
+		t.Errorf("Expected liveness status 200, got %d", res.Status)
+	}
+}
+
+func TestShutdownDelayKeepsServing(t *testing.T) {
+	h := health.New()
+	server := http.NewServer(func(req *http.Request, res *http.Response) {})
+	server.ShutdownDelay = 100 * time.Millisecond
+	h.Attach(&server)
+
+	done := make(chan error)
+	go func() {
+		done <- server.Shutdown(context.Background())
+	}()
+
+	// Readiness fails while the listeners still accept traffic
+	deadline := time.Now().Add(time.Second)
+	for h.Readiness(context.Background()).Status != health.StatusFail {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected readiness to fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-server.ShutdownCh:
+		t.Error("Expected server to keep serving during the shutdown delay")
+	default:
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-server.ShutdownCh:
+	default:
+		t.Error("Expected server to stop after the shutdown delay")
+	}
+}
+
+func TestShutdownCheckNameReserved(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for a readiness check named shutdown")
+		}
+	}()
+
+	health.New().AddReadinessCheck(health.ShutdownCheckName, func(ctx context.Context) error { return nil }, health.CheckOptions{})
+}
@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// DefBuckets are latency buckets in seconds, matching the Prometheus client defaults
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds metric families and renders them in the Prometheus text format
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// DefaultRegistry is used when no registry is passed explicitly
+var DefaultRegistry = NewRegistry()
+
+type family struct {
+	name       string
+	help       string
+	metricType metricType
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.RWMutex
+	series map[string]*series
+
+	// Value is read at exposition time (gauge funcs)
+	valueFunc func() float64
+}
+
+type series struct {
+	labelValues []string
+	value       atomicFloat
+
+	// Histogram state
+	bucketCounts []atomic.Uint64
+	count        atomic.Uint64
+}
+
+// family returns the existing family with name, or registers a new one. Registering a name
+// twice with a different type or labels is a programming error and panics.
+func (r *Registry) family(name, help string, metricType metricType, labelNames []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, found := r.families[name]; found {
+		if f.metricType != metricType || !slices.Equal(f.labelNames, labelNames) {
+			panic(fmt.Sprintf("metrics: %s already registered as %s with labels %v", name, f.metricType, f.labelNames))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	s, found := f.series[key]
+	f.mu.RUnlock()
+	if found {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, found := f.series[key]; found {
+		return s
+	}
+
+	s = &series{
+		labelValues: slices.Clone(labelValues),
+	}
+	if f.metricType == typeHistogram {
+		s.bucketCounts = make([]atomic.Uint64, len(f.buckets))
+	}
+	f.series[key] = s
+	return s
+}
+
+type Counter struct {
+	series *series
+}
+
+// Inc increments the counter by 1
+func (c Counter) Inc() {
+	c.series.value.Add(1)
+}
+
+// Add increments the counter by v, which must not be negative
+func (c Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.series.value.Add(v)
+}
+
+func (c Counter) Value() float64 {
+	return c.series.value.Load()
+}
+
+type CounterVec struct {
+	family *family
+}
+
+func (c CounterVec) WithLabelValues(labelValues ...string) Counter {
+	return Counter{series: c.family.with(labelValues)}
+}
+
+func (r *Registry) Counter(name, help string) Counter {
+	return Counter{series: r.family(name, help, typeCounter, nil, nil).with(nil)}
+}
+
+func (r *Registry) CounterVec(name, help string, labelNames ...string) CounterVec {
+	return CounterVec{family: r.family(name, help, typeCounter, labelNames, nil)}
+}
+
+type Gauge struct {
+	series *series
+}
+
+func (g Gauge) Set(v float64) {
+	g.series.value.Store(v)
+}
+
+func (g Gauge) Inc() {
+	g.series.value.Add(1)
+}
+
+func (g Gauge) Dec() {
+	g.series.value.Add(-1)
+}
+
+func (g Gauge) Add(v float64) {
+	g.series.value.Add(v)
+}
+
+func (g Gauge) Value() float64 {
+	return g.series.value.Load()
+}
+
+type GaugeVec struct {
+	family *family
+}
+
+func (g GaugeVec) WithLabelValues(labelValues ...string) Gauge {
+	return Gauge{series: g.family.with(labelValues)}
+}
+
+func (r *Registry) Gauge(name, help string) Gauge {
+	return Gauge{series: r.family(name, help, typeGauge, nil, nil).with(nil)}
+}
+
+func (r *Registry) GaugeVec(name, help string, labelNames ...string) GaugeVec {
+	return GaugeVec{family: r.family(name, help, typeGauge, labelNames, nil)}
+}
+
+// GaugeFunc registers a gauge whose value is computed by fn at exposition time
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	f := r.family(name, help, typeGauge, nil, nil)
+
+	f.mu.Lock()
+	f.valueFunc = fn
+	f.mu.Unlock()
+}
+
+type Histogram struct {
+	series  *series
+	buckets []float64
+}
+
+func (h Histogram) Observe(v float64) {
+	// Buckets are cumulative at exposition, only count the first matching bucket here
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.series.bucketCounts[i].Add(1)
+	}
+	h.series.count.Add(1)
+	h.series.value.Add(v)
+}
+
+type HistogramVec struct {
+	family *family
+}
+
+func (h HistogramVec) WithLabelValues(labelValues ...string) Histogram {
+	return Histogram{series: h.family.with(labelValues), buckets: h.family.buckets}
+}
+
+func (r *Registry) Histogram(name, help string, buckets []float64) Histogram {
+	f := r.family(name, help, typeHistogram, nil, normalizeBuckets(buckets))
+	return Histogram{series: f.with(nil), buckets: f.buckets}
+}
+
+func (r *Registry) HistogramVec(name, help string, buckets []float64, labelNames ...string) HistogramVec {
+	return HistogramVec{family: r.family(name, help, typeHistogram, labelNames, normalizeBuckets(buckets))}
+}
+
+func normalizeBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	// +Inf is always exposed, so drop it from the explicit buckets
+	return slices.DeleteFunc(slices.Compact(buckets), func(b float64) bool {
+		return math.IsInf(b, 1)
+	})
+}
+
+// atomicFloat is a float64 updated with compare-and-swap
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) Store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := f.bits.Load()
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if f.bits.CompareAndSwap(old, updated) {
+			return
+		}
+	}
+}
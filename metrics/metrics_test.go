@@ -0,0 +1,70 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/freekieb7/gravel/metrics"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	requests := registry.CounterVec("requests_total", "Total requests.", "method")
+	requests.WithLabelValues("GET").Inc()
+	requests.WithLabelValues("GET").Add(2)
+	requests.WithLabelValues(`PO"ST`).Inc()
+
+	registry.Gauge("active", "Active connections.").Set(4)
+	registry.GaugeFunc("queue", "Queue depth.", func() float64 { return 7 })
+
+	latency := registry.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP active Active connections.
+# TYPE active gauge
+active 4
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 5.55
+latency_seconds_count 3
+# HELP queue Queue depth.
+# TYPE queue gauge
+queue 7
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET"} 3
+requests_total{method="PO\"ST"} 1
+`
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestRegistryReusesFamilies(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	registry.Counter("hits_total", "Hits.").Inc()
+	registry.Counter("hits_total", "Hits.").Inc()
+
+	if value := registry.Counter("hits_total", "Hits.").Value(); value != 2 {
+		t.Errorf("Expected shared counter value 2, got %v", value)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when registering a name with another type")
+		}
+	}()
+	registry.Gauge("hits_total", "Hits.")
+}
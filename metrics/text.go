@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText writes all metrics in the Prometheus text exposition format, sorted by name
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(families, func(a, b *family) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.writeText(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) writeText(bw *bufio.Writer) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.valueFunc == nil && len(f.series) == 0 {
+		return
+	}
+
+	bw.WriteString("# HELP ")
+	bw.WriteString(f.name)
+	bw.WriteByte(' ')
+	bw.WriteString(escapeHelp(f.help))
+	bw.WriteString("\n# TYPE ")
+	bw.WriteString(f.name)
+	bw.WriteByte(' ')
+	bw.WriteString(string(f.metricType))
+	bw.WriteByte('\n')
+
+	if f.valueFunc != nil {
+		writeSample(bw, f.name, nil, nil, "", "", f.valueFunc())
+		return
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+
+		if f.metricType != typeHistogram {
+			writeSample(bw, f.name, f.labelNames, s.labelValues, "", "", s.value.Load())
+			continue
+		}
+
+		var cumulative uint64
+		for i, upperBound := range f.buckets {
+			cumulative += s.bucketCounts[i].Load()
+			writeSample(bw, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(upperBound), float64(cumulative))
+		}
+		count := s.count.Load()
+		writeSample(bw, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(count))
+		writeSample(bw, f.name+"_sum", f.labelNames, s.labelValues, "", "", s.value.Load())
+		writeSample(bw, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(count))
+	}
+}
+
+func writeSample(bw *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	bw.WriteString(name)
+
+	if len(labelNames) > 0 || extraName != "" {
+		bw.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(labelName)
+			bw.WriteString(`="`)
+			bw.WriteString(escapeLabelValue(labelValues[i]))
+			bw.WriteByte('"')
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteString(extraName)
+			bw.WriteString(`="`)
+			bw.WriteString(extraValue)
+			bw.WriteByte('"')
+		}
+		bw.WriteByte('}')
+	}
+
+	bw.WriteByte(' ')
+	bw.WriteString(formatFloat(value))
+	bw.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelReplacer.Replace(value)
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AccessLogFormat int
+
+const (
+	// AccessLogStructured logs every field as a slog attribute
+	AccessLogStructured AccessLogFormat = iota
+	// AccessLogJSON is AccessLogStructured written as JSON lines to stdout when no Logger is configured
+	AccessLogJSON
+	// AccessLogCommon logs the NCSA Common Log Format line as message
+	AccessLogCommon
+	// AccessLogCombined logs the Combined Log Format line (CLF with referer and user agent) as message
+	AccessLogCombined
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+type AccessLogConfig struct {
+	Logger *slog.Logger
+	Format AccessLogFormat
+	Level  slog.Level
+
+	// Fraction of requests to log (0 < rate <= 1), server errors are always logged
+	SampleRate float64
+
+	// Paths which are never logged, e.g. health checks
+	ExcludePaths []string
+}
+
+// AccessLogMiddleware logs one line per request once the handler has returned
+func AccessLogMiddleware(config AccessLogConfig) Middleware {
+	if config.Logger == nil {
+		if config.Format == AccessLogJSON {
+			config.Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		} else {
+			config.Logger = slog.Default()
+		}
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = 1
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			start := time.Now()
+
+			next(req, res)
+
+			if slices.Contains(config.ExcludePaths, string(req.Path)) {
+				return
+			}
+			if res.Status < StatusInternalServerError && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+				return
+			}
+
+			duration := time.Since(start)
+
+			switch config.Format {
+			case AccessLogCommon, AccessLogCombined:
+				config.Logger.Log(context.Background(), config.Level, formatCLF(req, res, start, config.Format == AccessLogCombined))
+			default:
+				config.Logger.LogAttrs(context.Background(), config.Level, "request",
+					slog.String("method", string(req.Method)),
+					slog.String("path", string(req.Path)),
+					slog.Int("status", int(res.Status)),
+					slog.Int("bytes", res.BytesWritten()),
+					slog.Duration("duration", duration),
+					slog.String("client_ip", GetClientIP(req)),
+					slog.String("request_id", accessLogRequestID(req)),
+				)
+			}
+		}
+	}
+}
+
+func accessLogRequestID(req *Request) string {
+	if id := RequestID(req); id != "" {
+		return id
+	}
+
+	// RequestIDMiddleware is not in use, log whatever the client sent
+	id, _ := req.Header([]byte(RequestIDHeader))
+	return string(id)
+}
+
+// formatCLF renders `host ident user [time] "request" status bytes` with optional `"referer" "user-agent"`
+func formatCLF(req *Request, res *Response, start time.Time, combined bool) string {
+	var b strings.Builder
+
+	user := "-"
+	if principal, found := GetPrincipal(req); found && principal.ID != "" {
+		user = clfField([]byte(principal.ID))
+	}
+
+	b.WriteString(GetClientIP(req))
+	b.WriteString(" - ")
+	b.WriteString(user)
+	b.WriteString(" [")
+	b.WriteString(start.Format(clfTimeFormat))
+	b.WriteString("] \"")
+	b.Write(req.Method)
+	b.WriteByte(' ')
+	b.Write(req.Path)
+	b.WriteByte(' ')
+	b.Write(req.Protocol)
+	b.WriteString("\" ")
+	b.WriteString(strconv.Itoa(int(res.Status)))
+	b.WriteByte(' ')
+	if size := res.BytesWritten(); size > 0 {
+		b.WriteString(strconv.Itoa(size))
+	} else {
+		b.WriteByte('-')
+	}
+
+	if combined {
+		referer, _ := req.Header([]byte("referer"))
+		userAgent, _ := req.Header([]byte("user-agent"))
+		b.WriteString(" \"")
+		b.WriteString(clfField(referer))
+		b.WriteString("\" \"")
+		b.WriteString(clfField(userAgent))
+		b.WriteByte('"')
+	}
+
+	return b.String()
+}
+
+func clfField(value []byte) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return strings.ReplaceAll(string(value), `"`, `\"`)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogStructured(t *testing.T) {
+	var buf bytes.Buffer
+	handler := AccessLogMiddleware(AccessLogConfig{
+		Logger:       slog.New(slog.NewJSONHandler(&buf, nil)),
+		ExcludePaths: []string{"/healthz"},
+	})(func(req *Request, res *Response) {
+		res.Status = StatusCreated
+		res.WithText("hello")
+	})
+
+	req := parseTestRequest(t, "POST /items HTTP/1.1\r\nX-Real-IP: 10.0.0.1\r\nX-Request-ID: abc\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected JSON log line, got %q: %v", buf.String(), err)
+	}
+	if entry["method"] != "POST" || entry["path"] != "/items" || entry["client_ip"] != "10.0.0.1" || entry["request_id"] != "abc" {
+		t.Errorf("Unexpected log entry %v", entry)
+	}
+	if entry["status"] != float64(201) || entry["bytes"] != float64(5) {
+		t.Errorf("Expected status 201 and 5 bytes, got %v and %v", entry["status"], entry["bytes"])
+	}
+
+	buf.Reset()
+	req = parseTestRequest(t, "GET /healthz HTTP/1.1\r\n\r\n")
+	res.Reset()
+	handler(req, res)
+	if buf.Len() != 0 {
+		t.Errorf("Expected excluded path not to be logged, got %q", buf.String())
+	}
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	req := parseTestRequest(t, "GET /index.html HTTP/1.1\r\nX-Real-IP: 10.0.0.1\r\nReferer: http://example.com/\r\nUser-Agent: test\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	res.WithText("hello")
+
+	start := time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC)
+	line := formatCLF(req, res, start, true)
+
+	expected := `10.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "GET /index.html HTTP/1.1" 200 5 "http://example.com/" "test"`
+	if line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+
+	res.Reset()
+	if line := formatCLF(req, res, start, false); !strings.HasSuffix(line, `" 200 -`) {
+		t.Errorf("Expected empty body to be logged as -, got %s", line)
+	}
+}
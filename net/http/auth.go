@@ -0,0 +1,272 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"slices"
+	"time"
+)
+
+const principalValueKey = "gravel.principal"
+
+// Principal is the authenticated caller, set by the authentication middleware of any scheme
+type Principal struct {
+	ID     string
+	Scheme string // "basic", "apikey" or "bearer"
+	Scopes []string
+}
+
+func (p Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func SetPrincipal(req *Request, principal Principal) {
+	req.SetValue(principalValueKey, principal)
+}
+
+// GetPrincipal returns the caller authenticated by BasicAuthMiddleware, APIKeyMiddleware or BearerAuthMiddleware
+func GetPrincipal(req *Request) (Principal, bool) {
+	value, found := req.Value(principalValueKey)
+	if !found {
+		return Principal{}, false
+	}
+
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// RequirePrincipal rejects requests without an authenticated principal holding all scopes
+func RequirePrincipal(scopes ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			principal, found := GetPrincipal(req)
+			if !found {
+				res.Status = StatusUnauthorized
+				res.WithText("unauthorized")
+				return
+			}
+
+			if !principal.HasScopes(scopes...) {
+				res.Status = StatusForbidden
+				res.WithText("forbidden")
+				return
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+// CredentialVerifier checks a username and password, returning the scopes granted to the user
+type CredentialVerifier func(username, password string) (scopes []string, ok bool)
+
+// StaticCredentials verifies against plain text passwords in constant time, it grants no scopes,
+// see WithUserScopes
+func StaticCredentials(users map[string]string) CredentialVerifier {
+	return func(username, password string) ([]string, bool) {
+		expected, found := users[username]
+		if !found {
+			// Compare anyway so unknown users take as long as wrong passwords
+			subtle.ConstantTimeCompare(sha256Sum(password), sha256Sum(password))
+			return nil, false
+		}
+
+		// Hash first, ConstantTimeCompare leaks length differences
+		return nil, subtle.ConstantTimeCompare(sha256Sum(expected), sha256Sum(password)) == 1
+	}
+}
+
+// HashedCredentials verifies against password hashes, compare has the signature of
+// bcrypt.CompareHashAndPassword so it can be passed directly. It grants no scopes, see WithUserScopes
+func HashedCredentials(users map[string]string, compare func(hashedPassword, password []byte) error) CredentialVerifier {
+	// A stored hash has the format and cost of the real ones, so unknown users take as long
+	// as wrong passwords
+	var dummyHash []byte
+	for _, hash := range users {
+		dummyHash = []byte(hash)
+		break
+	}
+
+	return func(username, password string) ([]string, bool) {
+		hash, found := users[username]
+		if !found {
+			if dummyHash != nil {
+				_ = compare(dummyHash, []byte(password))
+			}
+			return nil, false
+		}
+		return nil, compare([]byte(hash), []byte(password)) == nil
+	}
+}
+
+// WithUserScopes grants verified users their scopes, e.g.
+//
+//	WithUserScopes(StaticCredentials(passwords), map[string][]string{"admin": {"users:write"}})
+func WithUserScopes(verifier CredentialVerifier, scopes map[string][]string) CredentialVerifier {
+	return func(username, password string) ([]string, bool) {
+		if _, ok := verifier(username, password); !ok {
+			return nil, false
+		}
+		return scopes[username], true
+	}
+}
+
+type BasicAuthConfig struct {
+	Verifier CredentialVerifier
+	Realm    string
+}
+
+// BasicAuthMiddleware authenticates with RFC 7617 Basic credentials
+func BasicAuthMiddleware(config BasicAuthConfig) Middleware {
+	if config.Verifier == nil {
+		panic("http: BasicAuthConfig.Verifier is required")
+	}
+	if config.Realm == "" {
+		config.Realm = "restricted"
+	}
+	challenge := `Basic realm="` + quoteAuthParam(config.Realm) + `", charset="UTF-8"`
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			username, password, ok := basicCredentials(req)
+			if !ok {
+				res.Status = StatusUnauthorized
+				res.SetHeaderString("WWW-Authenticate", challenge)
+				return
+			}
+
+			scopes, ok := config.Verifier(username, password)
+			if !ok {
+				res.Status = StatusUnauthorized
+				res.SetHeaderString("WWW-Authenticate", challenge)
+				return
+			}
+
+			SetPrincipal(req, Principal{
+				ID:     username,
+				Scheme: "basic",
+				Scopes: scopes,
+			})
+
+			next(req, res)
+		}
+	}
+}
+
+func basicCredentials(req *Request) (string, string, bool) {
+	authorization, found := req.Header([]byte("authorization"))
+	if !found {
+		return "", "", false
+	}
+
+	scheme, encoded, found := bytes.Cut(authorization, []byte(" "))
+	if !found || !bytes.EqualFold(scheme, []byte("basic")) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return "", "", false
+	}
+
+	username, password, found := bytes.Cut(decoded, []byte(":"))
+	if !found {
+		return "", "", false
+	}
+	return string(username), string(password), true
+}
+
+type APIKey struct {
+	Key string
+	// Principal id, give rotated keys of the same client the same id
+	ID     string
+	Scopes []string
+	// Keys are rejected after this moment, zero means no expiry
+	ExpiresAt time.Time
+}
+
+type APIKeyConfig struct {
+	// Active keys, add the new key before removing the old one to rotate without downtime
+	Keys []APIKey
+
+	// Header carrying the key, defaults to X-API-Key
+	Header string
+	// Optional query parameter fallback, e.g. "api_key"
+	QueryParam string
+
+	// Scopes every key must have for the protected routes
+	Scopes []string
+}
+
+// APIKeyMiddleware authenticates with a static API key from a header or query parameter
+func APIKeyMiddleware(config APIKeyConfig) Middleware {
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+
+	// Hash once so every comparison is over equal length values
+	hashes := make([][]byte, len(config.Keys))
+	for i, key := range config.Keys {
+		hashes[i] = sha256Sum(key.Key)
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			provided, found := req.Header([]byte(config.Header))
+			if (!found || len(provided) == 0) && config.QueryParam != "" {
+				provided, found = req.QueryParam([]byte(config.QueryParam))
+			}
+			if !found || len(provided) == 0 {
+				res.Status = StatusUnauthorized
+				res.WithText("missing api key")
+				return
+			}
+
+			providedHash := sha256Sum(string(provided))
+			now := time.Now()
+
+			// Check every key so timing does not reveal which key matched
+			match := -1
+			for i := range config.Keys {
+				if subtle.ConstantTimeCompare(hashes[i], providedHash) == 1 {
+					if config.Keys[i].ExpiresAt.IsZero() || now.Before(config.Keys[i].ExpiresAt) {
+						match = i
+					}
+				}
+			}
+
+			if match < 0 {
+				res.Status = StatusUnauthorized
+				res.WithText("invalid api key")
+				return
+			}
+
+			principal := Principal{
+				ID:     config.Keys[match].ID,
+				Scheme: "apikey",
+				Scopes: config.Keys[match].Scopes,
+			}
+			if !principal.HasScopes(config.Scopes...) {
+				res.Status = StatusForbidden
+				res.WithText("insufficient scope")
+				return
+			}
+
+			SetPrincipal(req, principal)
+
+			next(req, res)
+		}
+	}
+}
+
+func sha256Sum(value string) []byte {
+	sum := sha256.Sum256([]byte(value))
+	return sum[:]
+}
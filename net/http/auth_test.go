@@ -0,0 +1,143 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	var principal Principal
+	handler := BasicAuthMiddleware(BasicAuthConfig{
+		Verifier: StaticCredentials(map[string]string{"admin": "secret"}),
+		Realm:    "admin",
+	})(func(req *Request, res *Response) {
+		principal, _ = GetPrincipal(req)
+	})
+
+	tests := []struct {
+		credentials string
+		status      uint16
+	}{
+		{"", StatusUnauthorized},
+		{"admin:wrong", StatusUnauthorized},
+		{"unknown:secret", StatusUnauthorized},
+		{"admin:secret", StatusOK},
+	}
+
+	for _, test := range tests {
+		raw := "GET / HTTP/1.1\r\n"
+		if test.credentials != "" {
+			raw += "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(test.credentials)) + "\r\n"
+		}
+		req := parseTestRequest(t, raw+"\r\n")
+		res := &Response{}
+		res.Reset()
+		handler(req, res)
+
+		if res.Status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.credentials, test.status, res.Status)
+		}
+		if challenge, _ := res.Header([]byte("WWW-Authenticate")); res.Status == StatusUnauthorized && string(challenge) != `Basic realm="admin", charset="UTF-8"` {
+			t.Errorf("%q: unexpected challenge %q", test.credentials, challenge)
+		}
+	}
+
+	if principal.ID != "admin" || principal.Scheme != "basic" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+}
+
+func TestHashedCredentials(t *testing.T) {
+	compare := func(hashedPassword, password []byte) error {
+		if string(hashedPassword) != "hash:"+string(password) {
+			return errors.New("mismatch")
+		}
+		return nil
+	}
+	verifier := HashedCredentials(map[string]string{"admin": "hash:secret"}, compare)
+
+	if _, ok := verifier("admin", "secret"); !ok {
+		t.Error("Expected valid credentials")
+	}
+	if _, ok := verifier("admin", "other"); ok {
+		t.Error("Expected invalid credentials")
+	}
+
+	// Unknown users are compared against a stored hash as well
+	compared := 0
+	counting := HashedCredentials(map[string]string{"admin": "hash:secret"}, func(hashedPassword, password []byte) error {
+		compared++
+		return compare(hashedPassword, password)
+	})
+	if _, ok := counting("unknown", "secret"); ok || compared != 1 {
+		t.Errorf("Expected unknown user to be rejected after a comparison, got %d comparisons", compared)
+	}
+}
+
+func TestWithUserScopes(t *testing.T) {
+	verifier := WithUserScopes(StaticCredentials(map[string]string{"admin": "secret", "viewer": "secret"}), map[string][]string{
+		"admin": {"users:write"},
+	})
+
+	if scopes, ok := verifier("admin", "secret"); !ok || len(scopes) != 1 || scopes[0] != "users:write" {
+		t.Errorf("Expected admin scopes, got %v %v", scopes, ok)
+	}
+	if scopes, ok := verifier("viewer", "secret"); !ok || len(scopes) != 0 {
+		t.Errorf("Expected viewer without scopes, got %v %v", scopes, ok)
+	}
+	if scopes, ok := verifier("admin", "wrong"); ok || scopes != nil {
+		t.Errorf("Expected no scopes for a wrong password, got %v %v", scopes, ok)
+	}
+}
+
+func TestBasicAuthMiddlewareRequiresVerifier(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic without Verifier")
+		}
+	}()
+	BasicAuthMiddleware(BasicAuthConfig{})
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	var principal Principal
+	handler := APIKeyMiddleware(APIKeyConfig{
+		Keys: []APIKey{
+			{Key: "old-key", ID: "service", Scopes: []string{"read"}, ExpiresAt: time.Now().Add(-time.Minute)},
+			{Key: "new-key", ID: "service", Scopes: []string{"read"}},
+			{Key: "write-only", ID: "other", Scopes: []string{"write"}},
+		},
+		QueryParam: "api_key",
+		Scopes:     []string{"read"},
+	})(RequirePrincipal("read")(func(req *Request, res *Response) {
+		principal, _ = GetPrincipal(req)
+	}))
+
+	tests := []struct {
+		raw    string
+		status uint16
+	}{
+		{"GET / HTTP/1.1\r\n\r\n", StatusUnauthorized},
+		{"GET / HTTP/1.1\r\nX-API-Key: old-key\r\n\r\n", StatusUnauthorized},
+		{"GET / HTTP/1.1\r\nX-API-Key: write-only\r\n\r\n", StatusForbidden},
+		{"GET /?api_key=new-key HTTP/1.1\r\n\r\n", StatusOK},
+		{"GET / HTTP/1.1\r\nX-API-Key: new-key\r\n\r\n", StatusOK},
+	}
+
+	for _, test := range tests {
+		req := parseTestRequest(t, test.raw)
+		res := &Response{}
+		res.Reset()
+		handler(req, res)
+
+		if res.Status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.raw, test.status, res.Status)
+		}
+	}
+
+	if principal.ID != "service" || principal.Scheme != "apikey" {
+		t.Errorf("Unexpected principal %+v", principal)
+	}
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/freekieb7/gravel/auth/oauth"
+)
+
+const jwtClaimsValueKey = "gravel.jwt_claims"
+
+// TokenValidator validates a bearer token, every oauth.OAuthProvider implements it. Errors wrapping
+// oauth.ErrJWKSUnavailable or a context error are not blamed on the token
+type TokenValidator interface {
+	ValidateToken(tokenString string) (*oauth.JWTPayload, error)
+}
+
+var _ TokenValidator = (oauth.OAuthProvider)(nil)
+
+// ContextTokenValidator is implemented by validators which fetch keys remotely, e.g. the oauth
+// clients, the request context then bounds the fetch and carries the request id
+type ContextTokenValidator interface {
+	ValidateTokenContext(ctx context.Context, tokenString string) (*oauth.JWTPayload, error)
+}
+
+var _ ContextTokenValidator = (*oauth.BaseOAuthClient)(nil)
+
+type BearerAuthConfig struct {
+	Validator TokenValidator
+
+	// Required claims, empty values are not checked
+	Issuer   string
+	Audience string
+	Scopes   []string
+
+	// Realm reported in the WWW-Authenticate challenge
+	Realm string
+}
+
+// BearerAuthMiddleware validates `Authorization: Bearer <jwt>` and stores the claims on the request,
+// failures are reported as described in RFC 6750
+func BearerAuthMiddleware(config BearerAuthConfig) Middleware {
+	if config.Validator == nil {
+		panic("http: BearerAuthConfig.Validator is required")
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			authorization, found := req.Header([]byte("authorization"))
+			if !found {
+				// No error code when the client did not attempt to authenticate (RFC 6750, 3.1)
+				bearerChallenge(res, config.Realm, StatusUnauthorized, "", "", nil)
+				return
+			}
+
+			token, ok := bearerToken(authorization)
+			if !ok {
+				bearerChallenge(res, config.Realm, StatusBadRequest, "invalid_request", "malformed authorization header", nil)
+				return
+			}
+
+			var claims *oauth.JWTPayload
+			var err error
+			if validator, ok := config.Validator.(ContextTokenValidator); ok {
+				claims, err = validator.ValidateTokenContext(req.Context(), token)
+			} else {
+				claims, err = config.Validator.ValidateToken(token)
+			}
+			if err != nil {
+				if errors.Is(err, oauth.ErrJWKSUnavailable) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+					RequestLogger(req).Error("bearer token validation unavailable", "error", err)
+					res.Status = StatusServiceUnavailable
+					return
+				}
+
+				// The reason stays in the log, it may describe keys or claims to the client
+				RequestLogger(req).Warn("bearer token rejected", "error", err)
+				bearerChallenge(res, config.Realm, StatusUnauthorized, "invalid_token", "token is invalid", nil)
+				return
+			}
+
+			if config.Issuer != "" && claims.Iss != config.Issuer {
+				bearerChallenge(res, config.Realm, StatusUnauthorized, "invalid_token", "unexpected issuer", nil)
+				return
+			}
+
+			if config.Audience != "" && claims.Aud != config.Audience {
+				bearerChallenge(res, config.Realm, StatusUnauthorized, "invalid_token", "unexpected audience", nil)
+				return
+			}
+
+			if !hasScopes(claims, config.Scopes) {
+				bearerChallenge(res, config.Realm, StatusForbidden, "insufficient_scope", "", config.Scopes)
+				return
+			}
+
+			req.SetValue(jwtClaimsValueKey, claims)
+			SetPrincipal(req, Principal{
+				ID:     claims.Sub,
+				Scheme: "bearer",
+				Scopes: strings.Fields(claims.Scope),
+			})
+
+			next(req, res)
+		}
+	}
+}
+
+// RequireScopes is a per route addition to BearerAuthMiddleware, which must run first
+func RequireScopes(realm string, scopes ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			claims, found := JWTClaims(req)
+			if !found {
+				bearerChallenge(res, realm, StatusUnauthorized, "", "", nil)
+				return
+			}
+
+			if !hasScopes(claims, scopes) {
+				bearerChallenge(res, realm, StatusForbidden, "insufficient_scope", "", scopes)
+				return
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+// JWTClaims returns the claims of the token validated by BearerAuthMiddleware
+func JWTClaims(req *Request) (*oauth.JWTPayload, bool) {
+	value, found := req.Value(jwtClaimsValueKey)
+	if !found {
+		return nil, false
+	}
+
+	claims, ok := value.(*oauth.JWTPayload)
+	return claims, ok
+}
+
+func bearerToken(authorization []byte) (string, bool) {
+	scheme, token, found := bytes.Cut(authorization, []byte(" "))
+	if !found || !bytes.EqualFold(scheme, []byte("bearer")) {
+		return "", false
+	}
+
+	token = bytes.TrimSpace(token)
+	if len(token) == 0 {
+		return "", false
+	}
+	return string(token), true
+}
+
+func hasScopes(claims *oauth.JWTPayload, required []string) bool {
+	granted := strings.Fields(claims.Scope)
+	for _, scope := range required {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// bearerChallenge writes the RFC 6750 WWW-Authenticate header and error status
+func bearerChallenge(res *Response, realm string, status uint16, code, description string, scopes []string) {
+	var b strings.Builder
+	b.WriteString("Bearer")
+
+	params := make([]string, 0, 4)
+	if realm != "" {
+		params = append(params, `realm="`+quoteAuthParam(realm)+`"`)
+	}
+	if code != "" {
+		params = append(params, `error="`+code+`"`)
+	}
+	if description != "" {
+		params = append(params, `error_description="`+quoteAuthParam(description)+`"`)
+	}
+	if len(scopes) > 0 {
+		params = append(params, `scope="`+quoteAuthParam(strings.Join(scopes, " "))+`"`)
+	}
+	if len(params) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(strings.Join(params, ", "))
+	}
+
+	res.Status = status
+	res.SetHeaderString("WWW-Authenticate", b.String())
+	res.SetHeaderString("Cache-Control", "no-store")
+}
+
+// quoteAuthParam keeps auth-param values within a quoted-string
+func quoteAuthParam(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '"' || r == '\\' || r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, value)
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/freekieb7/gravel/auth/oauth"
+)
+
+type stubTokenValidator map[string]*oauth.JWTPayload
+
+func (v stubTokenValidator) ValidateToken(tokenString string) (*oauth.JWTPayload, error) {
+	if tokenString == "unreachable-jwks" {
+		return nil, fmt.Errorf("%w: connection refused", oauth.ErrJWKSUnavailable)
+	}
+	claims, found := v[tokenString]
+	if !found {
+		return nil, errors.New("token has expired")
+	}
+	return claims, nil
+}
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	validator := stubTokenValidator{
+		"good":         {Iss: "https://issuer", Aud: "api", Sub: "user-1", Scope: "read write"},
+		"wrong-aud":    {Iss: "https://issuer", Aud: "other", Scope: "read"},
+		"missing-read": {Iss: "https://issuer", Aud: "api", Scope: "write"},
+	}
+
+	var subject string
+	handler := BearerAuthMiddleware(BearerAuthConfig{
+		Validator: validator,
+		Issuer:    "https://issuer",
+		Audience:  "api",
+		Scopes:    []string{"read"},
+		Realm:     "example",
+	})(func(req *Request, res *Response) {
+		claims, _ := JWTClaims(req)
+		subject = claims.Sub
+	})
+
+	tests := []struct {
+		authorization string
+		status        uint16
+		challenge     string
+	}{
+		{"", StatusUnauthorized, `Bearer realm="example"`},
+		{"Basic abc", StatusBadRequest, `Bearer realm="example", error="invalid_request", error_description="malformed authorization header"`},
+		{"Bearer expired", StatusUnauthorized, `Bearer realm="example", error="invalid_token", error_description="token is invalid"`},
+		{"Bearer unreachable-jwks", StatusServiceUnavailable, ""},
+		{"Bearer wrong-aud", StatusUnauthorized, `Bearer realm="example", error="invalid_token", error_description="unexpected audience"`},
+		{"Bearer missing-read", StatusForbidden, `Bearer realm="example", error="insufficient_scope", scope="read"`},
+		{"bearer good", StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		raw := "GET / HTTP/1.1\r\n"
+		if test.authorization != "" {
+			raw += "Authorization: " + test.authorization + "\r\n"
+		}
+		req := parseTestRequest(t, raw+"\r\n")
+		res := &Response{}
+		res.Reset()
+		handler(req, res)
+
+		if res.Status != test.status {
+			t.Errorf("%q: expected status %d, got %d", test.authorization, test.status, res.Status)
+		}
+		challenge, _ := res.Header([]byte("WWW-Authenticate"))
+		if string(challenge) != test.challenge {
+			t.Errorf("%q: expected challenge %q, got %q", test.authorization, test.challenge, challenge)
+		}
+	}
+
+	if subject != "user-1" {
+		t.Errorf("Expected claims on request, got subject %q", subject)
+	}
+}
+
+func TestBearerAuthMiddlewareRequiresValidator(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic without Validator")
+		}
+	}()
+	BearerAuthMiddleware(BearerAuthConfig{})
+}
+
+func TestRequireScopes(t *testing.T) {
+	validator := stubTokenValidator{"token": {Scope: "read"}}
+	handler := BearerAuthMiddleware(BearerAuthConfig{Validator: validator})(
+		RequireScopes("", "admin")(func(req *Request, res *Response) {}),
+	)
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nAuthorization: Bearer token\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	if res.Status != StatusForbidden {
+		t.Errorf("Expected status 403, got %d", res.Status)
+	}
+}
@@ -0,0 +1,283 @@
+package http
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/freekieb7/gravel/validation"
+)
+
+// Struct tags naming where Bind reads a field from, in increasing precedence after the JSON body
+var bindSources = []string{"form", "query", "header", "path"}
+
+// BindError is returned by Bind, Status is 400 for undecodable input and 422 for failed validation
+type BindError struct {
+	Status     uint16
+	Violations validation.Violations
+}
+
+func (e *BindError) Error() string {
+	var fields []string
+	for field, fieldErrors := range e.Violations.Errors {
+		for _, err := range fieldErrors {
+			fields = append(fields, field+": "+err.Error())
+		}
+	}
+	return "bind: " + strings.Join(fields, "; ")
+}
+
+// Bind decodes a JSON body into v, a pointer to a struct, then sets fields tagged with
+// path, query, header or form. Tagged fields are only read from their source, never from the body, e.g.
+//
+//	type CreateUser struct {
+//		Org   string `path:"org"`
+//		Name  string `json:"name" validate:"required,max:64"`
+//		Token string `header:"X-Invite-Token"`
+//	}
+//
+// Finally the rules of the validate tag are checked with the validation package
+func Bind(req *Request, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: expected a pointer to a struct, got %T", v)
+	}
+	target = target.Elem()
+
+	decodeErrors := validation.Violations{Errors: make(map[string][]error)}
+
+	if len(req.Body) > 0 && isJSONContentType(req) {
+		if err := bindJSON(req.Body, target); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				decodeErrors.Errors[typeErr.Field] = append(decodeErrors.Errors[typeErr.Field], fmt.Errorf("%s must be %s", typeErr.Field, typeErr.Type))
+			} else {
+				decodeErrors.Errors["body"] = append(decodeErrors.Errors["body"], errors.New("body is not valid json"))
+			}
+		}
+	}
+
+	data := make(map[string]any)
+	rules := make(map[string][]string)
+
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := bindFieldName(field)
+
+		for _, source := range bindSources {
+			key, found := field.Tag.Lookup(source)
+			if !found {
+				continue
+			}
+
+			raw, found := bindValue(req, source, key)
+			if !found {
+				continue
+			}
+			if err := setBindValue(target.Field(i), raw); err != nil {
+				decodeErrors.Errors[name] = append(decodeErrors.Errors[name], fmt.Errorf("%s %s", name, err))
+			}
+		}
+
+		if rule := field.Tag.Get("validate"); rule != "" {
+			value := validationValue(target.Field(i))
+			fieldRules := strings.Split(rule, ",")
+
+			// Absent optional fields (nil pointers) are only checked when required
+			if value == nil && !slices.Contains(fieldRules, "required") {
+				continue
+			}
+			data[name] = value
+			rules[name] = fieldRules
+		}
+	}
+
+	if !decodeErrors.IsEmpty() {
+		return &BindError{Status: StatusBadRequest, Violations: decodeErrors}
+	}
+
+	if violations := validation.ValidateMap(data, rules); !violations.IsEmpty() {
+		return &BindError{Status: StatusUnprocessableEntity, Violations: violations}
+	}
+	return nil
+}
+
+// BindOrReject binds like Bind and writes the violations as JSON when it fails, e.g.
+//
+//	var input CreateUser
+//	if !http.BindOrReject(req, res, &input) {
+//		return
+//	}
+func BindOrReject(req *Request, res *Response, v any) bool {
+	err := Bind(req, v)
+	if err == nil {
+		return true
+	}
+
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		// Programming error, e.g. a non pointer target
+		RequestLogger(req).Error("request binding failed", "error", err)
+		res.Status = StatusInternalServerError
+		return false
+	}
+
+	res.Status = bindErr.Status
+	res.WithJSON(bindErr.Violations)
+	return false
+}
+
+// bindJSON decodes the body into a copy of target and only keeps the fields without a source tag, so
+// a body cannot fill e.g. a path or header bound field the request did not carry
+func bindJSON(body []byte, target reflect.Value) error {
+	decoded := reflect.New(target.Type()).Elem()
+	decoded.Set(target)
+
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		if targetType.Field(i).IsExported() && hasBindSource(targetType.Field(i)) {
+			// Shared pointers, maps or slices must not be decoded into either
+			decoded.Field(i).SetZero()
+		}
+	}
+
+	if err := json.Unmarshal(body, decoded.Addr().Interface()); err != nil {
+		return err
+	}
+
+	for i := 0; i < targetType.NumField(); i++ {
+		if targetType.Field(i).IsExported() && !hasBindSource(targetType.Field(i)) {
+			target.Field(i).Set(decoded.Field(i))
+		}
+	}
+	return nil
+}
+
+func hasBindSource(field reflect.StructField) bool {
+	for _, source := range bindSources {
+		if _, found := field.Tag.Lookup(source); found {
+			return true
+		}
+	}
+	return false
+}
+
+// bindFieldName is the name reported in violations, the first source tag, the json name or the field name
+func bindFieldName(field reflect.StructField) string {
+	for _, source := range bindSources {
+		if key, found := field.Tag.Lookup(source); found && key != "" {
+			return key
+		}
+	}
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func bindValue(req *Request, source, key string) (string, bool) {
+	switch source {
+	case "path":
+		return req.Param(key)
+	case "query":
+		value, found := req.QueryParam([]byte(key))
+		return string(value), found
+	case "header":
+		value, found := req.Header([]byte(key))
+		return string(value), found
+	case "form":
+		return req.FormValue(key)
+	}
+	return "", false
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func setBindValue(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return setBindValue(field.Elem(), raw)
+	}
+
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw)); err != nil {
+			return errors.New("is invalid")
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		field.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		field.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(value)
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// validationValue converts a field to the types the validation rules understand
+func validationValue(field reflect.Value) any {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(field.Uint())
+	}
+	return field.Interface()
+}
+
+func isJSONContentType(req *Request) bool {
+	contentType, found := req.Header([]byte("content-type"))
+	if !found {
+		return false
+	}
+
+	mediaType, _, _ := bytes.Cut(contentType, []byte(";"))
+	mediaType = bytes.ToLower(bytes.TrimSpace(mediaType))
+	return bytes.Equal(mediaType, []byte("application/json")) || bytes.HasSuffix(mediaType, []byte("+json"))
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+type bindTestInput struct {
+	Org      string   `path:"org"`
+	Page     int      `query:"page" validate:"max:100"`
+	Tenant   string   `header:"X-Tenant"`
+	Name     string   `json:"name" validate:"required,max:10"`
+	Tags     []string `json:"tags"`
+	Nickname *string  `json:"nickname" validate:"min:2"`
+}
+
+func bindTestRequest(t *testing.T, target, body string) *Request {
+	t.Helper()
+
+	req := parseTestRequest(t, "POST "+target+" HTTP/1.1\r\nHost: example.com\r\nX-Tenant: acme\r\n"+
+		"Content-Type: application/json\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body)
+	matchRoutePath("/orgs/{org}/users", nil, string(req.Path), req)
+	return req
+}
+
+func TestBind(t *testing.T) {
+	req := bindTestRequest(t, "/orgs/gravel/users?page=2", `{"name":"freek","tags":["a"]}`)
+
+	var input bindTestInput
+	if err := Bind(req, &input); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if input.Org != "gravel" || input.Page != 2 || input.Tenant != "acme" || input.Name != "freek" || len(input.Tags) != 1 {
+		t.Errorf("Unexpected binding %+v", input)
+	}
+	if input.Nickname != nil {
+		t.Errorf("Expected absent optional field to stay nil")
+	}
+}
+
+func TestBindIgnoresBodyForSourceFields(t *testing.T) {
+	body := `{"name":"freek","Org":"other","Tenant":"evil","page":3}`
+	req := parseTestRequest(t, "POST /orgs/gravel/users HTTP/1.1\r\nHost: example.com\r\n"+
+		"Content-Type: application/json\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body)
+	matchRoutePath("/orgs/{org}/users", nil, string(req.Path), req)
+
+	input := bindTestInput{Tenant: "default"}
+	if err := Bind(req, &input); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if input.Org != "gravel" {
+		t.Errorf("Expected path field gravel, got %s", input.Org)
+	}
+	if input.Tenant != "default" {
+		t.Errorf("Expected absent header field to keep default, got %s", input.Tenant)
+	}
+	if input.Page != 0 {
+		t.Errorf("Expected absent query field to stay 0, got %d", input.Page)
+	}
+	if input.Name != "freek" {
+		t.Errorf("Expected name freek, got %s", input.Name)
+	}
+}
+
+func TestBindOrReject(t *testing.T) {
+	tests := []struct {
+		target string
+		body   string
+		status uint16
+		field  string
+	}{
+		{"/orgs/gravel/users?page=abc", `{"name":"freek"}`, StatusBadRequest, "page"},
+		{"/orgs/gravel/users", `{"name":`, StatusBadRequest, "body"},
+		{"/orgs/gravel/users", `{"name":1}`, StatusBadRequest, "name"},
+		{"/orgs/gravel/users", `{}`, StatusUnprocessableEntity, "name"},
+		{"/orgs/gravel/users?page=500", `{"name":"freek"}`, StatusUnprocessableEntity, "page"},
+		{"/orgs/gravel/users", `{"name":"freek","nickname":"x"}`, StatusUnprocessableEntity, "nickname"},
+	}
+
+	for _, tt := range tests {
+		var res Response
+		res.Reset()
+
+		var input bindTestInput
+		if BindOrReject(bindTestRequest(t, tt.target, tt.body), &res, &input) {
+			t.Errorf("Expected %s %s to be rejected", tt.target, tt.body)
+			continue
+		}
+		if res.Status != tt.status {
+			t.Errorf("Expected status %d for %s %s, got %d", tt.status, tt.target, tt.body, res.Status)
+		}
+
+		var payload struct {
+			Errors map[string][]string `json:"errors"`
+		}
+		if err := json.Unmarshal(res.Body, &payload); err != nil {
+			t.Fatalf("Expected violations json, got %q", res.Body)
+		}
+		if len(payload.Errors[tt.field]) == 0 {
+			t.Errorf("Expected violation for %s, got %s", tt.field, res.Body)
+		}
+	}
+}
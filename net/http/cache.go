@@ -0,0 +1,452 @@
+package http
+
+import (
+	"bytes"
+	"container/list"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a stored response, entries must not be modified once stored
+type CachedResponse struct {
+	Status  uint16
+	Headers []CachedHeader
+	Body    []byte
+	ETag    string
+
+	StoredAt             time.Time
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+
+	// Request headers selecting the variant, an entry with Status 0 only records Vary for its path
+	Vary []string
+}
+
+type CachedHeader struct {
+	Name  string
+	Value string
+}
+
+// ResponseCacheStore keeps cached responses, ttl is the duration after which an entry may be dropped
+type ResponseCacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, response *CachedResponse, ttl time.Duration)
+	Delete(key string)
+}
+
+type ResponseCacheConfig struct {
+	// Defaults to NewMemoryCacheStore(DefaultCacheEntries)
+	Store ResponseCacheStore
+
+	// Freshness of responses without max-age or s-maxage, zero caches only responses which set one
+	DefaultMaxAge time.Duration
+}
+
+const DefaultCacheEntries = 1024
+
+// Statuses which are cacheable by default (RFC 9110, 15.1)
+var cacheableStatuses = []uint16{
+	StatusOK, StatusNonAuthoritativeInfo, StatusNoContent, StatusMultipleChoices,
+	StatusMovedPermanently, StatusNotFound, StatusMethodNotAllowed, StatusGone,
+}
+
+// ResponseCacheMiddleware caches GET and HEAD responses as a shared cache, honoring the Cache-Control
+// header set by the handler. Only headers set by the handler and inner middleware are stored
+func ResponseCacheMiddleware(config ResponseCacheConfig) Middleware {
+	if config.Store == nil {
+		config.Store = NewMemoryCacheStore(DefaultCacheEntries)
+	}
+
+	var revalidating sync.Map
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			method := string(req.Method)
+			if method != http.MethodGet && method != http.MethodHead {
+				next(req, res)
+				return
+			}
+
+			requestControl := parseCacheControl(req.Header([]byte("cache-control")))
+			key := responseCacheKey(req)
+
+			// no-cache and no-store requests skip the lookup and fetch a fresh response
+			if !requestControl.noCache && !requestControl.noStore {
+				if entry, found := lookupCachedResponse(config.Store, key, req); found {
+					age := time.Since(entry.StoredAt)
+					if age <= entry.MaxAge {
+						serveCachedResponse(req, res, entry, age, "HIT")
+						return
+					}
+
+					if age <= entry.MaxAge+entry.StaleWhileRevalidate {
+						// One refresh per key at a time, other requests keep getting the stale entry
+						if _, busy := revalidating.LoadOrStore(key, struct{}{}); !busy {
+							go func(req *Request) {
+								defer revalidating.Delete(key)
+								revalidateCachedResponse(config, key, req, next)
+							}(req.clone())
+						}
+
+						serveCachedResponse(req, res, entry, age, "STALE")
+						return
+					}
+				}
+			}
+
+			start := res.headerCount
+			next(req, res)
+
+			if requestControl.noStore {
+				return
+			}
+
+			entry, ok := newCachedResponse(config, req, res, start)
+			if !ok {
+				return
+			}
+			storeCachedResponse(config.Store, key, req, entry)
+
+			res.ReplaceHeaderString("ETag", entry.ETag)
+			res.ReplaceHeaderString("X-Cache", "MISS")
+			if res.Status == StatusOK && notModified(req, entry) {
+				res.Status = StatusNotModified
+				res.Body = nil
+			}
+		}
+	}
+}
+
+func revalidateCachedResponse(config ResponseCacheConfig, key string, req *Request, next Handler) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			slog.Error("cache revalidation panicked", "key", key, "panic", recovered)
+		}
+	}()
+
+	var res Response
+	res.Reset()
+	next(req, &res)
+
+	if entry, ok := newCachedResponse(config, req, &res, 0); ok {
+		storeCachedResponse(config.Store, key, req, entry)
+	}
+}
+
+// newCachedResponse captures the response when the handler allows it to be stored by a shared cache
+func newCachedResponse(config ResponseCacheConfig, req *Request, res *Response, start int) (*CachedResponse, bool) {
+	if res.Chunked || res.streamed > 0 || !slices.Contains(cacheableStatuses, res.Status) {
+		return nil, false
+	}
+	if _, found := res.Header([]byte("set-cookie")); found {
+		return nil, false
+	}
+
+	control := parseCacheControl(res.Header([]byte("cache-control")))
+	if control.noStore || control.noCache || control.private {
+		return nil, false
+	}
+	if _, found := req.Header([]byte("authorization")); found && !control.public {
+		return nil, false
+	}
+
+	maxAge := config.DefaultMaxAge
+	if control.sMaxAge >= 0 {
+		maxAge = time.Duration(control.sMaxAge) * time.Second
+	} else if control.maxAge >= 0 {
+		maxAge = time.Duration(control.maxAge) * time.Second
+	}
+	if maxAge <= 0 {
+		return nil, false
+	}
+
+	var vary []string
+	if value, found := res.Header([]byte("vary")); found {
+		for _, name := range strings.Split(string(value), ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
+		}
+	}
+
+	entry := captureResponse(res, start)
+	entry.StoredAt = time.Now()
+	entry.MaxAge = maxAge
+	entry.StaleWhileRevalidate = time.Duration(max(control.staleWhileRevalidate, 0)) * time.Second
+	entry.Vary = vary
+
+	entry.Headers = slices.DeleteFunc(entry.Headers, func(header CachedHeader) bool {
+		if strings.EqualFold(header.Name, "etag") {
+			entry.ETag = header.Value
+			return true
+		}
+		return strings.EqualFold(header.Name, "age") || strings.EqualFold(header.Name, "x-cache")
+	})
+
+	if entry.ETag == "" {
+		hash := fnv.New64a()
+		hash.Write(entry.Body)
+		entry.ETag = `"` + strconv.FormatUint(hash.Sum64(), 16) + `"`
+	}
+
+	return entry, true
+}
+
+func storeCachedResponse(store ResponseCacheStore, key string, req *Request, entry *CachedResponse) {
+	ttl := entry.MaxAge + entry.StaleWhileRevalidate
+	if len(entry.Vary) == 0 {
+		store.Set(key, entry, ttl)
+		return
+	}
+
+	store.Set(key, &CachedResponse{Vary: entry.Vary, StoredAt: entry.StoredAt}, ttl)
+	store.Set(varyCacheKey(key, entry.Vary, req), entry, ttl)
+}
+
+func lookupCachedResponse(store ResponseCacheStore, key string, req *Request) (*CachedResponse, bool) {
+	entry, found := store.Get(key)
+	if !found || entry.Status != 0 {
+		return entry, found
+	}
+
+	return store.Get(varyCacheKey(key, entry.Vary, req))
+}
+
+func serveCachedResponse(req *Request, res *Response, entry *CachedResponse, age time.Duration, state string) {
+	res.Status = entry.Status
+	res.Body = entry.Body
+
+	applyCachedHeaders(res, entry.Headers)
+
+	res.ReplaceHeaderString("ETag", entry.ETag)
+	res.ReplaceHeaderString("Age", strconv.Itoa(int(age/time.Second)))
+	res.ReplaceHeaderString("X-Cache", state)
+
+	if entry.Status == StatusOK && notModified(req, entry) {
+		res.Status = StatusNotModified
+		res.Body = nil
+	}
+}
+
+// captureResponse copies the status, body and the headers from index start onwards
+func captureResponse(res *Response, start int) *CachedResponse {
+	entry := &CachedResponse{
+		Status: res.Status,
+		Body:   bytes.Clone(res.Body),
+	}
+
+	for i := start; i < res.headerCount; i++ {
+		h := res.header(i)
+		entry.Headers = append(entry.Headers, CachedHeader{Name: string(h.Name[:h.NameLen]), Value: string(res.headerValue(i))})
+	}
+	return entry
+}
+
+// applyCachedHeaders replaces headers of the same name, but keeps repeated stored headers (e.g. Link)
+func applyCachedHeaders(res *Response, headers []CachedHeader) {
+	for i, header := range headers {
+		if slices.IndexFunc(headers[:i], func(h CachedHeader) bool { return strings.EqualFold(h.Name, header.Name) }) >= 0 {
+			res.addHeader([]byte(header.Name), []byte(header.Value))
+		} else {
+			res.ReplaceHeaderString(header.Name, header.Value)
+		}
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when absent (RFC 9110, 13.2.2)
+func notModified(req *Request, entry *CachedResponse) bool {
+	if ifNoneMatch, found := req.Header([]byte("if-none-match")); found {
+		for _, tag := range strings.Split(string(ifNoneMatch), ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(entry.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ifModifiedSince, found := req.Header([]byte("if-modified-since"))
+	if !found {
+		return false
+	}
+	since, err := http.ParseTime(string(ifModifiedSince))
+	if err != nil {
+		return false
+	}
+
+	for _, header := range entry.Headers {
+		if strings.EqualFold(header.Name, "last-modified") {
+			lastModified, err := http.ParseTime(header.Value)
+			return err == nil && !lastModified.After(since)
+		}
+	}
+	return false
+}
+
+// responseCacheKey is the method, host, path and query, query parameters keep their request order
+func responseCacheKey(req *Request) string {
+	var b strings.Builder
+	b.Write(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.Host())
+	b.Write(req.Path)
+	for i := 0; i < req.queryParamsCount; i++ {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		param := &req.queryParams[i]
+		b.Write(param.Name[:param.NameLen])
+		b.WriteByte('=')
+		b.Write(param.Value[:param.ValueLen])
+	}
+	return b.String()
+}
+
+func varyCacheKey(key string, vary []string, req *Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		value, _ := req.Header([]byte(name))
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	return b.String()
+}
+
+type cacheControl struct {
+	noStore bool
+	noCache bool
+	private bool
+	public  bool
+
+	// Seconds, -1 when absent
+	maxAge               int
+	sMaxAge              int
+	staleWhileRevalidate int
+}
+
+func parseCacheControl(value []byte, found bool) cacheControl {
+	control := cacheControl{maxAge: -1, sMaxAge: -1, staleWhileRevalidate: -1}
+	if !found {
+		return control
+	}
+
+	for _, directive := range strings.Split(string(value), ",") {
+		name, argument, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds := -1
+		if argument != "" {
+			if n, err := strconv.Atoi(strings.Trim(argument, `"`)); err == nil && n >= 0 {
+				seconds = n
+			}
+		}
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			control.noStore = true
+		case "no-cache":
+			control.noCache = true
+		case "private":
+			control.private = true
+		case "public":
+			control.public = true
+		case "max-age":
+			control.maxAge = seconds
+		case "s-maxage":
+			control.sMaxAge = seconds
+		case "stale-while-revalidate":
+			control.staleWhileRevalidate = seconds
+		}
+	}
+	return control
+}
+
+// MemoryCacheStore is an in-memory LRU store, entries are not shared between processes
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key       string
+	response  *CachedResponse
+	expiresAt time.Time
+}
+
+// NewMemoryCacheStore keeps at most maxEntries responses, evicting the least recently used
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+func (store *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	element, found := store.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	if store.now().After(entry.expiresAt) {
+		store.remove(element)
+		return nil, false
+	}
+
+	store.lru.MoveToFront(element)
+	return entry.response, true
+}
+
+func (store *MemoryCacheStore) Set(key string, response *CachedResponse, ttl time.Duration) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	expiresAt := store.now().Add(ttl)
+	if element, found := store.entries[key]; found {
+		entry := element.Value.(*memoryCacheEntry)
+		entry.response = response
+		entry.expiresAt = expiresAt
+		store.lru.MoveToFront(element)
+		return
+	}
+
+	store.entries[key] = store.lru.PushFront(&memoryCacheEntry{key: key, response: response, expiresAt: expiresAt})
+	for store.maxEntries > 0 && store.lru.Len() > store.maxEntries {
+		store.remove(store.lru.Back())
+	}
+}
+
+func (store *MemoryCacheStore) Delete(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if element, found := store.entries[key]; found {
+		store.remove(element)
+	}
+}
+
+func (store *MemoryCacheStore) remove(element *list.Element) {
+	store.lru.Remove(element)
+	delete(store.entries, element.Value.(*memoryCacheEntry).key)
+}
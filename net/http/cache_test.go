@@ -0,0 +1,205 @@
+package http
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func serveCacheTest(t *testing.T, handler Handler, raw string) *Response {
+	t.Helper()
+
+	req := parseTestRequest(t, raw)
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+	return res
+}
+
+func TestResponseCacheMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	store := NewMemoryCacheStore(10)
+	handler := ResponseCacheMiddleware(ResponseCacheConfig{Store: store})(func(req *Request, res *Response) {
+		calls.Add(1)
+		res.SetHeaderString("Cache-Control", "max-age=60")
+		res.WithText("expensive " + strconv.Itoa(int(calls.Load())))
+	})
+
+	first := serveCacheTest(t, handler, "GET /report?year=2024 HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	second := serveCacheTest(t, handler, "GET /report?year=2024 HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	if calls.Load() != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", calls.Load())
+	}
+	if string(second.Body) != string(first.Body) {
+		t.Errorf("Expected cached body %q, got %q", first.Body, second.Body)
+	}
+	if state, _ := second.Header([]byte("X-Cache")); string(state) != "HIT" {
+		t.Errorf("Expected HIT, got %q", state)
+	}
+	if contentType, _ := second.Header([]byte("Content-Type")); len(contentType) == 0 {
+		t.Error("Expected cached headers to be replayed")
+	}
+
+	// Query is part of the key
+	serveCacheTest(t, handler, "GET /report?year=2025 HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	if calls.Load() != 2 {
+		t.Errorf("Expected a different query to miss, handler ran %d times", calls.Load())
+	}
+
+	// Revalidation with the ETag gets an empty 304
+	etag, _ := first.Header([]byte("ETag"))
+	revalidated := serveCacheTest(t, handler, "GET /report?year=2024 HTTP/1.1\r\nHost: example.com\r\nIf-None-Match: "+string(etag)+"\r\n\r\n")
+	if revalidated.Status != StatusNotModified || len(revalidated.Body) != 0 {
+		t.Errorf("Expected empty 304, got %d with %q", revalidated.Status, revalidated.Body)
+	}
+
+	// Request no-cache bypasses the stored response
+	serveCacheTest(t, handler, "GET /report?year=2024 HTTP/1.1\r\nHost: example.com\r\nCache-Control: no-cache\r\n\r\n")
+	if calls.Load() != 3 {
+		t.Errorf("Expected no-cache request to reach the handler, ran %d times", calls.Load())
+	}
+}
+
+func TestResponseCacheNotStored(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		handler Handler
+	}{
+		{"no-store", "GET / HTTP/1.1\r\n\r\n", func(req *Request, res *Response) {
+			res.SetHeaderString("Cache-Control", "no-store")
+		}},
+		{"private", "GET / HTTP/1.1\r\n\r\n", func(req *Request, res *Response) {
+			res.SetHeaderString("Cache-Control", "private, max-age=60")
+		}},
+		{"no max-age", "GET / HTTP/1.1\r\n\r\n", func(req *Request, res *Response) {}},
+		{"server error", "GET / HTTP/1.1\r\n\r\n", func(req *Request, res *Response) {
+			res.Status = StatusInternalServerError
+			res.SetHeaderString("Cache-Control", "max-age=60")
+		}},
+		{"set-cookie", "GET / HTTP/1.1\r\n\r\n", func(req *Request, res *Response) {
+			res.SetHeaderString("Cache-Control", "max-age=60")
+			res.SetCookieValue("sid", "secret")
+		}},
+		{"authorization", "GET / HTTP/1.1\r\nAuthorization: Bearer token\r\n\r\n", func(req *Request, res *Response) {
+			res.SetHeaderString("Cache-Control", "max-age=60")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := ResponseCacheMiddleware(ResponseCacheConfig{})(func(req *Request, res *Response) {
+				calls++
+				tt.handler(req, res)
+			})
+
+			serveCacheTest(t, handler, tt.request)
+			serveCacheTest(t, handler, tt.request)
+			if calls != 2 {
+				t.Errorf("Expected response not to be cached, handler ran %d times", calls)
+			}
+		})
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	calls := 0
+	handler := ResponseCacheMiddleware(ResponseCacheConfig{})(func(req *Request, res *Response) {
+		calls++
+		language, _ := req.Header([]byte("accept-language"))
+		res.SetHeaderString("Cache-Control", "max-age=60")
+		res.SetHeaderString("Vary", "Accept-Language")
+		res.WithText(string(language))
+	})
+
+	serveCacheTest(t, handler, "GET / HTTP/1.1\r\nAccept-Language: nl\r\n\r\n")
+	english := serveCacheTest(t, handler, "GET / HTTP/1.1\r\nAccept-Language: en\r\n\r\n")
+	dutch := serveCacheTest(t, handler, "GET / HTTP/1.1\r\nAccept-Language: nl\r\n\r\n")
+
+	if calls != 2 {
+		t.Errorf("Expected one handler call per language, got %d", calls)
+	}
+	if string(english.Body) != "en" || string(dutch.Body) != "nl" {
+		t.Errorf("Expected variants en and nl, got %q and %q", english.Body, dutch.Body)
+	}
+}
+
+func TestResponseCacheHost(t *testing.T) {
+	handler := ResponseCacheMiddleware(ResponseCacheConfig{})(func(req *Request, res *Response) {
+		res.SetHeaderString("Cache-Control", "max-age=60")
+		res.WithText(req.Host())
+	})
+
+	serveCacheTest(t, handler, "GET / HTTP/1.1\r\nHost: tenant-a.example.com\r\n\r\n")
+	other := serveCacheTest(t, handler, "GET / HTTP/1.1\r\nHost: tenant-b.example.com\r\n\r\n")
+
+	if string(other.Body) != "tenant-b.example.com" {
+		t.Errorf("Expected response of tenant-b.example.com, got %q", other.Body)
+	}
+}
+
+func TestResponseCacheStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	store := NewMemoryCacheStore(10)
+	handler := ResponseCacheMiddleware(ResponseCacheConfig{Store: store})(func(req *Request, res *Response) {
+		n := calls.Add(1)
+		res.SetHeaderString("Cache-Control", "max-age=1, stale-while-revalidate=60")
+		res.WithText("version " + strconv.Itoa(int(n)))
+	})
+
+	serveCacheTest(t, handler, "GET /feed HTTP/1.1\r\n\r\n")
+
+	// Age the entry past max-age
+	entry, _ := store.Get("GET /feed")
+	entry.StoredAt = entry.StoredAt.Add(-2 * time.Second)
+
+	stale := serveCacheTest(t, handler, "GET /feed HTTP/1.1\r\n\r\n")
+	if string(stale.Body) != "version 1" {
+		t.Errorf("Expected stale body, got %q", stale.Body)
+	}
+	if state, _ := stale.Header([]byte("X-Cache")); string(state) != "STALE" {
+		t.Errorf("Expected STALE, got %q", state)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if entry, _ := store.Get("GET /feed"); string(entry.Body) == "version 2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the entry to be refreshed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	fresh := serveCacheTest(t, handler, "GET /feed HTTP/1.1\r\n\r\n")
+	if string(fresh.Body) != "version 2" {
+		t.Errorf("Expected refreshed body, got %q", fresh.Body)
+	}
+}
+
+func TestMemoryCacheStoreLRU(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	store.Set("a", &CachedResponse{Status: StatusOK}, time.Minute)
+	store.Set("b", &CachedResponse{Status: StatusOK}, time.Minute)
+	store.Get("a")
+	store.Set("c", &CachedResponse{Status: StatusOK}, time.Minute)
+
+	if _, found := store.Get("b"); found {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if _, found := store.Get("a"); !found {
+		t.Error("Expected recently used entry to be kept")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, found := store.Get("c"); found {
+		t.Error("Expected expired entry to be dropped")
+	}
+}
@@ -0,0 +1,278 @@
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+type CSRFMode int
+
+const (
+	// CSRFModeSession stores the synchronizer token in the session (requires SessionMiddleware)
+	CSRFModeSession CSRFMode = iota + 1
+	// CSRFModeDoubleSubmit stores the token in a cookie which must be echoed back in a header or form field
+	CSRFModeDoubleSubmit
+)
+
+var ErrCSRFNoSession = errors.New("csrf: session mode requires SessionMiddleware")
+
+const (
+	csrfSessionKey = "_csrf_token"
+	csrfValueKey   = "gravel.csrf_token"
+	csrfTokenSize  = 32
+)
+
+type CSRFConfig struct {
+	Mode CSRFMode
+
+	// Names under which the token is accepted on unsafe requests
+	HeaderName string
+	FieldName  string
+
+	// Cookie used in double submit mode
+	CookieName   string
+	CookiePath   string
+	CookieSecure bool
+
+	// Additional origins (scheme://host[:port]) allowed besides the request host
+	TrustedOrigins []string
+
+	// Generate a new token after every successful unsafe request
+	RotatePerRequest bool
+
+	// Called when validation fails, defaults to a plain 403
+	ErrorHandler Handler
+}
+
+func DefaultCSRFConfig() CSRFConfig {
+	return CSRFConfig{
+		Mode:         CSRFModeSession,
+		HeaderName:   "X-CSRF-Token",
+		FieldName:    "_csrf",
+		CookieName:   "CSRF-TOKEN",
+		CookiePath:   "/",
+		CookieSecure: true,
+		ErrorHandler: func(req *Request, res *Response) {
+			res.Status = StatusForbidden
+			res.WithText("invalid csrf token")
+		},
+	}
+}
+
+// CSRFMiddleware protects unsafe methods with a synchronizer or double submit token
+func CSRFMiddleware(config CSRFConfig) Middleware {
+	config = config.withDefaults()
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			token, err := loadCSRFToken(config, req)
+			if err != nil {
+				slog.Error("csrf token unavailable", "error", err)
+				res.Status = StatusInternalServerError
+				return
+			}
+
+			if !isSafeMethod(req.Method) {
+				if !validCSRFOrigin(config, req) {
+					config.ErrorHandler(req, res)
+					return
+				}
+
+				if token == "" || !validCSRFToken(token, submittedCSRFToken(config, req)) {
+					config.ErrorHandler(req, res)
+					return
+				}
+
+				if config.RotatePerRequest {
+					token = ""
+				}
+			}
+
+			if token == "" {
+				token, err = newCSRFToken()
+				if err != nil {
+					slog.Error("failed to generate csrf token", "error", err)
+					res.Status = StatusInternalServerError
+					return
+				}
+
+				if err := storeCSRFToken(config, req, res, token); err != nil {
+					slog.Error("failed to store csrf token", "error", err)
+					res.Status = StatusInternalServerError
+					return
+				}
+			}
+
+			req.SetValue(csrfValueKey, token)
+
+			next(req, res)
+		}
+	}
+}
+
+// CSRFToken returns the token for the current request, to be embedded in forms or headers
+func CSRFToken(req *Request) string {
+	value, found := req.Value(csrfValueKey)
+	if !found {
+		return ""
+	}
+
+	token, _ := value.(string)
+	return token
+}
+
+// CSRFField returns a hidden form input containing the current token
+func CSRFField(req *Request) string {
+	return CSRFFieldNamed(req, DefaultCSRFConfig().FieldName)
+}
+
+// CSRFFieldNamed is CSRFField for a middleware configured with a custom FieldName
+func CSRFFieldNamed(req *Request, fieldName string) string {
+	return `<input type="hidden" name="` + html.EscapeString(fieldName) + `" value="` + html.EscapeString(CSRFToken(req)) + `">`
+}
+
+// RotateCSRFToken replaces the current token, e.g. after login or a privilege change
+func RotateCSRFToken(config CSRFConfig, req *Request, res *Response) (string, error) {
+	config = config.withDefaults()
+
+	token, err := newCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := storeCSRFToken(config, req, res, token); err != nil {
+		return "", err
+	}
+
+	req.SetValue(csrfValueKey, token)
+	return token, nil
+}
+
+func (config CSRFConfig) withDefaults() CSRFConfig {
+	defaults := DefaultCSRFConfig()
+	if config.Mode == 0 {
+		config.Mode = defaults.Mode
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = defaults.HeaderName
+	}
+	if config.FieldName == "" {
+		config.FieldName = defaults.FieldName
+	}
+	if config.CookieName == "" {
+		config.CookieName = defaults.CookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = defaults.CookiePath
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = defaults.ErrorHandler
+	}
+	return config
+}
+
+func loadCSRFToken(config CSRFConfig, req *Request) (string, error) {
+	switch config.Mode {
+	case CSRFModeDoubleSubmit:
+		cookie, err := req.Cookie([]byte(config.CookieName))
+		if err != nil {
+			return "", nil
+		}
+		return cookie.Value, nil
+	default:
+		sess, found := GetSession(req)
+		if !found {
+			return "", ErrCSRFNoSession
+		}
+		token, _ := sess.Get(csrfSessionKey, "").(string)
+		return token, nil
+	}
+}
+
+func storeCSRFToken(config CSRFConfig, req *Request, res *Response, token string) error {
+	switch config.Mode {
+	case CSRFModeDoubleSubmit:
+		// Readable by scripts so they can echo it in the header
+		res.AddCookie(Cookie{
+			Name:     config.CookieName,
+			Value:    token,
+			Path:     config.CookiePath,
+			Secure:   config.CookieSecure,
+			SameSite: SameSiteStrictMode,
+		})
+		return nil
+	default:
+		sess, found := GetSession(req)
+		if !found {
+			return ErrCSRFNoSession
+		}
+		sess.Set(csrfSessionKey, token)
+		return nil
+	}
+}
+
+func submittedCSRFToken(config CSRFConfig, req *Request) string {
+	if token, found := req.Header([]byte(config.HeaderName)); found && len(token) > 0 {
+		return string(token)
+	}
+
+	token, _ := req.FormValue(config.FieldName)
+	return token
+}
+
+func validCSRFToken(expected, actual string) bool {
+	if actual == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// validCSRFOrigin compares Origin (or Referer as fallback) against the Host header
+func validCSRFOrigin(config CSRFConfig, req *Request) bool {
+	source, found := req.Header([]byte("origin"))
+	if !found || string(source) == "null" {
+		source, found = req.Header([]byte("referer"))
+	}
+
+	if !found {
+		// Browsers always send a Referer on same origin HTTPS posts, unless stripped by policy
+		return !IsSecureScheme(req)
+	}
+
+	origin, err := url.Parse(string(source))
+	if err != nil || origin.Host == "" {
+		return false
+	}
+
+	host, _ := req.Header([]byte("host"))
+	if strings.EqualFold(origin.Host, string(host)) {
+		return true
+	}
+
+	return slices.ContainsFunc(config.TrustedOrigins, func(trusted string) bool {
+		return strings.EqualFold(trusted, origin.Scheme+"://"+origin.Host)
+	})
+}
+
+func newCSRFToken() (string, error) {
+	raw := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func isSafeMethod(method []byte) bool {
+	switch string(method) {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
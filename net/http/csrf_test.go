@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/freekieb7/gravel/session"
+)
+
+func parseTestRequest(t *testing.T, raw string) *Request {
+	t.Helper()
+
+	req := &Request{}
+	req.Reset()
+	if err := req.Parse(bufio.NewReader(strings.NewReader(raw))); err != nil {
+		t.Fatalf("Request parse failed: %v", err)
+	}
+	return req
+}
+
+func TestCSRFSessionMode(t *testing.T) {
+	sess := session.NewDefaultSession("sid", "test", make(map[string]any))
+	handler := CSRFMiddleware(DefaultCSRFConfig())(func(req *Request, res *Response) {
+		res.WithText(CSRFToken(req))
+	})
+
+	// Safe request generates a token
+	req := parseTestRequest(t, "GET /form HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	req.SetValue(sessionValueKey, sess)
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	token := string(res.Body)
+	if token == "" {
+		t.Fatal("Expected token to be generated")
+	}
+	if sess.Get(csrfSessionKey, "") != token {
+		t.Error("Expected token to be stored in session")
+	}
+	if !strings.Contains(CSRFField(req), `value="`+token+`"`) {
+		t.Errorf("Expected hidden field to contain token, got %s", CSRFField(req))
+	}
+
+	// Unsafe request without token is rejected
+	req = parseTestRequest(t, "POST /form HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	req.SetValue(sessionValueKey, sess)
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusForbidden {
+		t.Errorf("Expected status 403, got %d", res.Status)
+	}
+
+	// Unsafe request with form token is accepted
+	body := "_csrf=" + token
+	req = parseTestRequest(t, "POST /form HTTP/1.1\r\nHost: example.com\r\nOrigin: http://example.com\r\n"+
+		"Content-Type: application/x-www-form-urlencoded\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body)
+	req.SetValue(sessionValueKey, sess)
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusOK {
+		t.Errorf("Expected status 200, got %d", res.Status)
+	}
+
+	// Cross origin request with valid token is rejected
+	req = parseTestRequest(t, "POST /form HTTP/1.1\r\nHost: example.com\r\nOrigin: https://evil.com\r\nX-CSRF-Token: "+token+"\r\n\r\n")
+	req.SetValue(sessionValueKey, sess)
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusForbidden {
+		t.Errorf("Expected status 403 for cross origin, got %d", res.Status)
+	}
+}
+
+func TestCSRFRotatePerRequest(t *testing.T) {
+	sess := session.NewDefaultSession("sid", "test", map[string]any{csrfSessionKey: "old"})
+	config := DefaultCSRFConfig()
+	config.RotatePerRequest = true
+	handler := CSRFMiddleware(config)(func(req *Request, res *Response) {})
+
+	req := parseTestRequest(t, "POST /form HTTP/1.1\r\nHost: example.com\r\nX-CSRF-Token: old\r\n\r\n")
+	req.SetValue(sessionValueKey, sess)
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	if res.Status != StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.Status)
+	}
+	if token := CSRFToken(req); token == "" || token == "old" {
+		t.Errorf("Expected rotated token, got %q", token)
+	}
+}
+
+func TestCSRFDoubleSubmitMode(t *testing.T) {
+	config := DefaultCSRFConfig()
+	config.Mode = CSRFModeDoubleSubmit
+	handler := CSRFMiddleware(config)(func(req *Request, res *Response) {})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	cookie, found := res.Header([]byte("set-cookie"))
+	if !found || !strings.HasPrefix(string(cookie), "CSRF-TOKEN="+CSRFToken(req)) {
+		t.Errorf("Expected csrf cookie, got %s", cookie)
+	}
+
+	req = parseTestRequest(t, "POST / HTTP/1.1\r\nHost: example.com\r\nCookie: CSRF-TOKEN=abc\r\nX-CSRF-Token: abc\r\n\r\n")
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusOK {
+		t.Errorf("Expected status 200, got %d", res.Status)
+	}
+
+	req = parseTestRequest(t, "POST / HTTP/1.1\r\nHost: example.com\r\nCookie: CSRF-TOKEN=abc\r\nX-CSRF-Token: xyz\r\n\r\n")
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusForbidden {
+		t.Errorf("Expected status 403, got %d", res.Status)
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"maps"
+	"runtime/debug"
+	"strconv"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// HTTPError is an error with the status and RFC 9457 problem details sent to the client.
+// Err is only logged, it never reaches the client
+type HTTPError struct {
+	Status uint16
+	// URI identifying the problem type, defaults to "about:blank"
+	Type string
+	// Short summary, defaults to the status message
+	Title  string
+	Detail string
+	// Additional members of the problem object, e.g. validation errors
+	Extensions map[string]any
+
+	Err error
+}
+
+func NewHTTPError(status uint16, detail string) *HTTPError {
+	return &HTTPError{Status: status, Detail: detail}
+}
+
+func (e *HTTPError) Error() string {
+	message := strconv.Itoa(int(e.Status)) + " " + e.title()
+	if e.Detail != "" {
+		message += ": " + e.Detail
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HTTPError) title() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	if message := StatusText(e.Status); message != "" {
+		return message
+	}
+	return "Unknown"
+}
+
+// ErrorHandlerFunc is a handler which reports failures by returning an error, see HandleErrors
+type ErrorHandlerFunc func(req *Request, res *Response) error
+
+// ErrorRenderer writes err to the response
+type ErrorRenderer func(req *Request, res *Response, err *HTTPError)
+
+// DefaultErrorRenderer renders errors of HandleErrors and RecoverMiddleware, replace it to change every error page
+var DefaultErrorRenderer ErrorRenderer = NegotiatedErrorRenderer
+
+// HandleErrors adapts h to a Handler, a returned *HTTPError is rendered with its status and any other
+// error as a 500 without exposing its message. Server errors are logged with a stack trace
+func HandleErrors(h ErrorHandlerFunc) Handler {
+	return func(req *Request, res *Response) {
+		err := h(req, res)
+		if err == nil {
+			return
+		}
+
+		RenderError(req, res, err)
+	}
+}
+
+// RenderError converts err to an *HTTPError, logs server errors and renders it with DefaultErrorRenderer
+func RenderError(req *Request, res *Response, err error) {
+	httpErr := asHTTPError(err)
+	if httpErr.Status >= StatusInternalServerError {
+		RequestLogger(req).Error("request failed",
+			"method", string(req.Method),
+			"route", req.Route(),
+			"status", httpErr.Status,
+			"error", err,
+			"stack", string(debug.Stack()),
+		)
+	}
+
+	DefaultErrorRenderer(req, res, httpErr)
+}
+
+func asHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	var bindErr *BindError
+	if errors.As(err, &bindErr) {
+		fields := make(map[string][]string, len(bindErr.Violations.Errors))
+		for field, fieldErrors := range bindErr.Violations.Errors {
+			for _, fieldErr := range fieldErrors {
+				fields[field] = append(fields[field], fieldErr.Error())
+			}
+		}
+
+		return &HTTPError{
+			Status:     bindErr.Status,
+			Detail:     "the request contains invalid fields",
+			Extensions: map[string]any{"errors": fields},
+			Err:        err,
+		}
+	}
+
+	return &HTTPError{Status: StatusInternalServerError, Err: err}
+}
+
+// NegotiatedErrorRenderer renders problem JSON, HTML or plain text depending on the Accept header
+func NegotiatedErrorRenderer(req *Request, res *Response, err *HTTPError) {
+	contentType, _ := NegotiateContentType(req, ProblemContentType, "application/json", "text/html", "text/plain")
+	addVary(res, "Accept")
+
+	switch contentType {
+	case "text/html":
+		res.Status = err.Status
+		body := "<!DOCTYPE html><html><head><title>" + html.EscapeString(err.title()) + "</title></head><body><h1>" +
+			html.EscapeString(err.title()) + "</h1>"
+		if err.Detail != "" {
+			body += "<p>" + html.EscapeString(err.Detail) + "</p>"
+		}
+		res.WithHTML(body + "</body></html>")
+	case "text/plain":
+		res.Status = err.Status
+		if err.Detail != "" {
+			res.WithText(err.title() + ": " + err.Detail)
+		} else {
+			res.WithText(err.title())
+		}
+	default:
+		ProblemRenderer(req, res, err)
+	}
+}
+
+// ProblemRenderer always renders an RFC 9457 application/problem+json body
+func ProblemRenderer(req *Request, res *Response, err *HTTPError) {
+	problem := make(map[string]any, len(err.Extensions)+5)
+	maps.Copy(problem, err.Extensions)
+
+	problem["type"] = "about:blank"
+	if err.Type != "" {
+		problem["type"] = err.Type
+	}
+	problem["title"] = err.title()
+	problem["status"] = err.Status
+	if err.Detail != "" {
+		problem["detail"] = err.Detail
+	}
+	if id := RequestID(req); id != "" {
+		problem["request_id"] = id
+	}
+
+	body, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		body = fmt.Appendf(nil, `{"type":"about:blank","title":%q,"status":%d}`, err.title(), err.Status)
+	}
+
+	res.Status = err.Status
+	res.Body = body
+	res.ReplaceHeaderString("content-type", ProblemContentType)
+}
@@ -0,0 +1,98 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		err    error
+		status uint16
+		check  func(t *testing.T, res *Response)
+	}{
+		{"problem", "", &HTTPError{Status: StatusNotFound, Type: "https://example.com/probs/missing", Detail: "user 42 does not exist"}, StatusNotFound, func(t *testing.T, res *Response) {
+			var problem map[string]any
+			if err := json.Unmarshal(res.Body, &problem); err != nil {
+				t.Fatalf("Expected problem json, got %q", res.Body)
+			}
+			if problem["type"] != "https://example.com/probs/missing" || problem["title"] != "Not Found" || problem["status"] != 404.0 || problem["detail"] != "user 42 does not exist" {
+				t.Errorf("Unexpected problem %v", problem)
+			}
+			if contentType, _ := res.Header([]byte("content-type")); string(contentType) != ProblemContentType {
+				t.Errorf("Expected %s, got %q", ProblemContentType, contentType)
+			}
+		}},
+		{"internal", "", errors.New("connection refused to db:5432"), StatusInternalServerError, func(t *testing.T, res *Response) {
+			if bytes.Contains(res.Body, []byte("db:5432")) {
+				t.Errorf("Expected internal error not to be exposed, got %q", res.Body)
+			}
+		}},
+		{"html", "text/html,*/*;q=0.8", NewHTTPError(StatusForbidden, "<admins only>"), StatusForbidden, func(t *testing.T, res *Response) {
+			if !bytes.Contains(res.Body, []byte("&lt;admins only&gt;")) {
+				t.Errorf("Expected escaped html detail, got %q", res.Body)
+			}
+		}},
+		{"text", "text/plain", NewHTTPError(StatusConflict, "already exists"), StatusConflict, func(t *testing.T, res *Response) {
+			if string(res.Body) != "Conflict: already exists" {
+				t.Errorf("Unexpected text body %q", res.Body)
+			}
+		}},
+		{"bind", "", &BindError{Status: StatusUnprocessableEntity}, StatusUnprocessableEntity, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := HandleErrors(func(req *Request, res *Response) error {
+				return tt.err
+			})
+
+			raw := "GET / HTTP/1.1\r\n"
+			if tt.accept != "" {
+				raw += "Accept: " + tt.accept + "\r\n"
+			}
+			var res Response
+			res.Reset()
+			handler(parseTestRequest(t, raw+"\r\n"), &res)
+
+			if res.Status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, res.Status)
+			}
+			if tt.check != nil {
+				tt.check(t, &res)
+			}
+		})
+	}
+}
+
+func TestRecoverMiddlewareLogsStack(t *testing.T) {
+	var logs bytes.Buffer
+	handler := RequestIDMiddleware()(func(req *Request, res *Response) {
+		req.SetValue(requestLoggerValueKey, slog.New(slog.NewJSONHandler(&logs, nil)).With("request_id", RequestID(req)))
+		RecoverMiddleware()(func(req *Request, res *Response) {
+			panic("nil map")
+		})(req, res)
+	})
+
+	var res Response
+	res.Reset()
+	handler(parseTestRequest(t, "GET / HTTP/1.1\r\nX-Request-ID: req-123\r\n\r\n"), &res)
+
+	if res.Status != StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", res.Status)
+	}
+	if !bytes.Contains(res.Body, []byte(`"request_id":"req-123"`)) {
+		t.Errorf("Expected request id in problem, got %q", res.Body)
+	}
+
+	line := logs.String()
+	if !strings.Contains(line, `"request_id":"req-123"`) || !strings.Contains(line, "panic: nil map") || !strings.Contains(line, "errors_test.go") {
+		t.Errorf("Expected panic logged with request id and stack, got %q", line)
+	}
+}
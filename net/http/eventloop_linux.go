@@ -0,0 +1,338 @@
+//go:build linux
+
+package http
+
+import (
+	"bufio"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const eventLoopSupported = true
+
+// States of an eventConn, only the owner of a busy connection changes its state
+const (
+	connIdle int32 = iota
+	connBusy
+	connClosed
+)
+
+// eventLoop waits for readable connections with epoll and hands them to a small worker pool. A
+// connection is registered one shot, so exactly one worker serves it until it is rearmed
+type eventLoop struct {
+	server *Server
+	epfd   int
+
+	mu     sync.Mutex
+	conns  map[int32]*eventConn // by file descriptor
+	nextID uint32
+
+	ready   chan *eventConn
+	stopCh  chan struct{}
+	pollers sync.WaitGroup
+	workers sync.WaitGroup
+}
+
+type eventConn struct {
+	conn net.Conn
+	raw  syscall.RawConn
+	fd   int32
+	// Tells a reused descriptor apart in events of a closed connection
+	id int32
+
+	state      atomic.Int32
+	lastActive atomic.Int64
+	// Only accessed by the worker serving the connection
+	requests int
+}
+
+func (s *Server) startEventLoop() (*eventLoop, error) {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := int(s.WorkerPoolSize)
+	if workers == 0 {
+		workers = runtime.NumCPU() * 64
+	}
+
+	loop := &eventLoop{
+		server: s,
+		epfd:   epfd,
+		conns:  make(map[int32]*eventConn),
+		ready:  make(chan *eventConn, workers),
+		stopCh: make(chan struct{}),
+	}
+
+	for range workers {
+		loop.workers.Add(1)
+		go loop.work()
+	}
+
+	loop.pollers.Add(2)
+	go loop.poll()
+	go loop.sweep()
+
+	return loop, nil
+}
+
+// add registers a new connection, connections without a file descriptor (e.g. TLS) get a goroutine
+func (loop *eventLoop) add(conn net.Conn) {
+	s := loop.server
+
+	var raw syscall.RawConn
+	if sysConn, ok := conn.(syscall.Conn); ok {
+		raw, _ = sysConn.SyscallConn()
+	}
+	if raw == nil {
+		loop.workers.Add(1)
+		go func() {
+			defer loop.workers.Done()
+
+			var req Request
+			var res Response
+			s.handleConnection(conn, bufio.NewReaderSize(nil, DefaultReadBufferSize), bufio.NewWriterSize(nil, DefaultWriteBufferSize), &req, &res)
+		}()
+		return
+	}
+
+	if s.Hooks.OnConnOpen != nil {
+		s.Hooks.OnConnOpen(conn)
+	}
+	if s.Metrics != nil {
+		s.Metrics.activeConnections.Inc()
+	}
+	s.tuneTCPConn(conn)
+
+	ec := &eventConn{conn: conn, raw: raw}
+	ec.lastActive.Store(time.Now().UnixNano())
+	_ = raw.Control(func(fd uintptr) {
+		ec.fd = int32(fd)
+	})
+
+	loop.mu.Lock()
+	loop.nextID++
+	ec.id = int32(loop.nextID)
+	loop.conns[ec.fd] = ec
+	loop.mu.Unlock()
+
+	if err := loop.arm(ec, unix.EPOLL_CTL_ADD); err != nil {
+		s.Logger.Error("epoll_ctl error", "error", err)
+		ec.state.Store(connClosed)
+		loop.release(ec)
+	}
+}
+
+// arm waits for the next readable event of the connection
+func (loop *eventLoop) arm(ec *eventConn, op int) error {
+	event := unix.EpollEvent{
+		Events: unix.EPOLLIN | unix.EPOLLRDHUP | unix.EPOLLONESHOT,
+		Fd:     ec.fd,
+		Pad:    ec.id,
+	}
+
+	var err error
+	if controlErr := ec.raw.Control(func(fd uintptr) {
+		err = unix.EpollCtl(loop.epfd, op, int(fd), &event)
+	}); controlErr != nil {
+		return controlErr
+	}
+	return err
+}
+
+func (loop *eventLoop) poll() {
+	defer loop.pollers.Done()
+
+	events := make([]unix.EpollEvent, 256)
+	for {
+		select {
+		case <-loop.stopCh:
+			return
+		default:
+		}
+
+		// Wake up regularly to notice stop
+		n, err := unix.EpollWait(loop.epfd, events, 100)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			loop.server.Logger.Error("epoll_wait error", "error", err)
+			return
+		}
+
+		for _, event := range events[:n] {
+			loop.mu.Lock()
+			ec := loop.conns[event.Fd]
+			loop.mu.Unlock()
+
+			if ec == nil || ec.id != event.Pad || !ec.state.CompareAndSwap(connIdle, connBusy) {
+				continue
+			}
+			// Blocks while every worker is busy
+			loop.ready <- ec
+		}
+	}
+}
+
+// sweep closes connections idle for longer than IdleTimeout
+func (loop *eventLoop) sweep() {
+	defer loop.pollers.Done()
+
+	timeout := loop.server.IdleTimeout
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(max(timeout/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-loop.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		loop.closeIdle(time.Now().Add(-timeout).UnixNano())
+	}
+}
+
+// closeIdle closes the idle connections last active before the unix nano timestamp
+func (loop *eventLoop) closeIdle(before int64) {
+	var idle []*eventConn
+
+	loop.mu.Lock()
+	for _, ec := range loop.conns {
+		if ec.lastActive.Load() < before && ec.state.Load() == connIdle {
+			idle = append(idle, ec)
+		}
+	}
+	loop.mu.Unlock()
+
+	for _, ec := range idle {
+		if ec.state.CompareAndSwap(connIdle, connClosed) {
+			loop.release(ec)
+		}
+	}
+}
+
+func (loop *eventLoop) work() {
+	defer loop.workers.Done()
+
+	br := bufio.NewReaderSize(nil, DefaultReadBufferSize)
+	bw := bufio.NewWriterSize(nil, DefaultWriteBufferSize)
+
+	req := Request{}
+	res := Response{}
+
+	for ec := range loop.ready {
+		loop.serve(ec, br, bw, &req, &res)
+	}
+}
+
+// serve answers the requests available on a busy connection, then rearms or closes it
+func (loop *eventLoop) serve(ec *eventConn, br *bufio.Reader, bw *bufio.Writer, req *Request, res *Response) {
+	s := loop.server
+
+	// A panicking handler only costs its connection
+	defer func() {
+		if r := recover(); r != nil {
+			s.Logger.Error("panic recovered", "error", r)
+			ec.state.Store(connClosed)
+			loop.release(ec)
+		}
+	}()
+
+	br.Reset(ec.conn)
+	bw.Reset(ec.conn)
+	req.RemoteAddr = ec.conn.RemoteAddr()
+
+	// Bounds reading the announced request, the connection is readable already
+	if err := ec.conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		s.Logger.Error("SetDeadline error", "error", err)
+	}
+
+	for {
+		ec.requests++
+		if ec.requests > maxRequestsPerConnection || !s.serveRequest(br, bw, req, res, ec.requests) {
+			if err := bw.Flush(); err != nil {
+				s.Logger.Error("Flush error", "error", err)
+			}
+			ec.state.Store(connClosed)
+			loop.release(ec)
+			return
+		}
+
+		// Pipelined requests are served before waiting again
+		if br.Buffered() == 0 {
+			break
+		}
+	}
+
+	select {
+	case <-loop.stopCh:
+		ec.state.Store(connClosed)
+		loop.release(ec)
+		return
+	default:
+	}
+
+	// bw is reset for the next connection of this worker, nothing may stay behind
+	if err := bw.Flush(); err != nil {
+		ec.state.Store(connClosed)
+		loop.release(ec)
+		return
+	}
+
+	// The connection must be idle before it is rearmed, the next event may arrive right away
+	ec.lastActive.Store(time.Now().UnixNano())
+	ec.state.Store(connIdle)
+	if err := loop.arm(ec, unix.EPOLL_CTL_MOD); err != nil && ec.state.CompareAndSwap(connIdle, connClosed) {
+		loop.release(ec)
+	}
+}
+
+// release closes a connection, called once by whoever moved it to connClosed
+func (loop *eventLoop) release(ec *eventConn) {
+	s := loop.server
+
+	loop.mu.Lock()
+	if loop.conns[ec.fd] == ec {
+		delete(loop.conns, ec.fd)
+	}
+	loop.mu.Unlock()
+
+	if err := ec.conn.Close(); err != nil {
+		s.Logger.Error("closing connection error", "error", err)
+	}
+	if s.Hooks.OnConnClose != nil {
+		s.Hooks.OnConnClose(ec.conn)
+	}
+	if s.Metrics != nil {
+		s.Metrics.activeConnections.Dec()
+	}
+}
+
+// stop waits for the workers to finish their connections and closes the idle ones
+func (loop *eventLoop) stop() {
+	close(loop.stopCh)
+	loop.pollers.Wait()
+
+	close(loop.ready)
+	loop.workers.Wait()
+
+	// Every remaining connection is idle
+	loop.closeIdle(time.Now().Add(time.Hour).UnixNano())
+
+	if err := unix.Close(loop.epfd); err != nil {
+		loop.server.Logger.Error("closing epoll error", "error", err)
+	}
+}
@@ -0,0 +1,244 @@
+//go:build linux
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newEventLoopTestServer(t testing.TB, eventLoop bool, workers uint32) (*Server, string) {
+	t.Helper()
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("hello " + string(req.Path))
+	})
+	s.EventLoop = eventLoop
+	s.WorkerPoolSize = workers
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	lns := listenTestServer(t, &s, "127.0.0.1:0")
+	return &s, lns[0].Addr().String()
+}
+
+// readTestResponse reads a response with a Content-Length body and returns the body
+func readTestResponse(br *bufio.Reader) (string, error) {
+	length := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "content-length") {
+			if _, err := fmt.Sscan(strings.TrimSpace(value), &length); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(br, body)
+	return string(body), err
+}
+
+func TestServerEventLoop(t *testing.T) {
+	s, addr := newEventLoopTestServer(t, true, 16)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	// Keep-alive requests, each waiting in the event loop in between
+	for _, path := range []string{"/one", "/two"} {
+		_, _ = conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: gravel\r\n\r\n"))
+		if body, err := readTestResponse(br); err != nil || body != "hello "+path {
+			t.Errorf("Expected hello %s, got %q %v", path, body, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Pipelined requests in a single write
+	_, _ = conn.Write([]byte("GET /a HTTP/1.1\r\nHost: gravel\r\n\r\nGET /b HTTP/1.1\r\nHost: gravel\r\n\r\n"))
+	for _, path := range []string{"/a", "/b"} {
+		if body, err := readTestResponse(br); err != nil || body != "hello "+path {
+			t.Errorf("Expected hello %s, got %q %v", path, body, err)
+		}
+	}
+
+	if body := getTestBody(t, addr); body != "hello /" {
+		t.Errorf("Expected hello /, got %q", body)
+	}
+
+	// The idle connection does not hold up the shutdown
+	start := time.Now()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected fast shutdown, took %v", elapsed)
+	}
+	if _, err := br.ReadByte(); err == nil {
+		t.Errorf("Expected the idle connection to be closed")
+	}
+}
+
+func TestServerEventLoopEmptyResponse(t *testing.T) {
+	for _, eventLoop := range []bool{false, true} {
+		s := NewServer(func(req *Request, res *Response) {})
+		s.EventLoop = eventLoop
+		s.WorkerPoolSize = 4
+		s.Logger = slog.New(slog.DiscardHandler)
+
+		lns := listenTestServer(t, &s, "127.0.0.1:0")
+
+		conn, err := net.Dial("tcp", lns[0].Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+
+		// The empty 200 fast path must reach the client without waiting for a deadline
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: gravel\r\n\r\n"))
+		if body, err := readTestResponse(bufio.NewReader(conn)); err != nil || body != "" {
+			t.Errorf("Expected an empty response with EventLoop=%v, got %q %v", eventLoop, body, err)
+		}
+
+		_ = conn.Close()
+		_ = s.Shutdown(context.Background())
+	}
+}
+
+func TestServerEventLoopIdleTimeout(t *testing.T) {
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("ok")
+	})
+	s.EventLoop = true
+	s.WorkerPoolSize = 4
+	s.IdleTimeout = time.Second
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	lns := listenTestServer(t, &s, "127.0.0.1:0")
+	defer s.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", lns[0].Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the idle connection to be closed, got %v", err)
+	}
+}
+
+func benchmarkKeepAlive(b *testing.B, eventLoop bool) {
+	s, addr := newEventLoopTestServer(b, eventLoop, 16)
+	defer s.Shutdown(context.Background())
+
+	request := []byte("GET /bench HTTP/1.1\r\nHost: gravel\r\n\r\n")
+
+	b.ReportAllocs()
+	b.SetParallelism(4)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Errorf("Dial failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+
+		for pb.Next() {
+			if _, err := conn.Write(request); err != nil {
+				b.Errorf("Write failed: %v", err)
+				return
+			}
+			if _, err := readTestResponse(br); err != nil {
+				b.Errorf("Read failed: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkServerKeepAliveWorkerPool(b *testing.B) {
+	benchmarkKeepAlive(b, false)
+}
+
+func BenchmarkServerKeepAliveEventLoop(b *testing.B) {
+	benchmarkKeepAlive(b, true)
+}
+
+// benchmarkIdleConns keeps many idle connections open next to one active client and reports the
+// goroutines the server needs for them. Both modes use their default worker count, an idle
+// connection holds up a worker of the pool
+func benchmarkIdleConns(b *testing.B, eventLoop bool) {
+	s, addr := newEventLoopTestServer(b, eventLoop, 0)
+	defer s.Shutdown(context.Background())
+
+	const idleConns = 1000
+	request := []byte("GET /idle HTTP/1.1\r\nHost: gravel\r\n\r\n")
+
+	var wg sync.WaitGroup
+	conns := make([]net.Conn, idleConns)
+	for i := range conns {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+
+		// A served request makes the connection an idle keep-alive connection
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = conn.Write(request)
+			_, _ = readTestResponse(bufio.NewReader(conn))
+		}()
+	}
+	wg.Wait()
+	goroutines := runtime.NumGoroutine()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		b.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	b.ResetTimer()
+	for range b.N {
+		_, _ = conn.Write(request)
+		if _, err := readTestResponse(br); err != nil {
+			b.Fatalf("Read failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(goroutines), "goroutines")
+}
+
+func BenchmarkServerIdleConnsWorkerPool(b *testing.B) {
+	benchmarkIdleConns(b, false)
+}
+
+func BenchmarkServerIdleConnsEventLoop(b *testing.B) {
+	benchmarkIdleConns(b, true)
+}
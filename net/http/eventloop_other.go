@@ -0,0 +1,20 @@
+//go:build !linux
+
+package http
+
+import (
+	"errors"
+	"net"
+)
+
+const eventLoopSupported = false
+
+type eventLoop struct{}
+
+func (s *Server) startEventLoop() (*eventLoop, error) {
+	return nil, errors.New("http: event loop is only supported on linux")
+}
+
+func (loop *eventLoop) add(conn net.Conn) {}
+
+func (loop *eventLoop) stop() {}
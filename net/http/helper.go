@@ -3,6 +3,7 @@ package http
 import (
 	"errors"
 	"mime"
+	"net"
 	"path/filepath"
 	"strings"
 )
@@ -76,7 +77,14 @@ func GetClientIP(req *Request) string {
 		return string(cfIP)
 	}
 
-	// Fallback - would need to get from connection in real implementation
+	// Fallback to the peer address of the connection, Unix socket peers have no address
+	if req.RemoteAddr != nil && req.RemoteAddr.Network() != "unix" {
+		if host, _, err := net.SplitHostPort(req.RemoteAddr.String()); err == nil {
+			return host
+		}
+		return req.RemoteAddr.String()
+	}
+
 	return "unknown"
 }
 
@@ -116,30 +124,6 @@ func atoi(b []byte) (int, error) {
 	return n, nil
 }
 
-// Helper function to write integer to buffer without allocation
-func writeIntToBuffer(n int, buf []byte) int {
-	if n == 0 {
-		buf[0] = '0'
-		return 1
-	}
-
-	// Calculate digits needed
-	temp := n
-	digits := 0
-	for temp > 0 {
-		digits++
-		temp /= 10
-	}
-
-	// Write digits backwards
-	for i := digits - 1; i >= 0; i-- {
-		buf[i] = '0' + byte(n%10)
-		n /= 10
-	}
-
-	return digits
-}
-
 // Convert integer to hex without allocation
 func writeHexToBuffer(n int, buf []byte) int {
 	if n == 0 {
@@ -4,6 +4,8 @@ const (
 	DefaultReadBufferSize  = 64 * 1024 // Increase buffer sizes
 	DefaultWriteBufferSize = 64 * 1024
 	ChannelBufferSize      = 2000 // Increase channel buffer
+	// Body bytes Response.Write buffers before the response switches to chunked encoding
+	ResponseBufferSize = 64 * 1024
 )
 
 type Handler func(req *Request, res *Response)
@@ -16,6 +18,7 @@ var (
 	headerConnection       = []byte("connection")
 	headerKeepAlive        = []byte("keep-alive")
 	headerClose            = []byte("close")
+	headerTrailer          = []byte("trailer")
 	// Pre-compute common response patterns
 	http200OK           = []byte("HTTP/1.1 200 OK\r\n")
 	connectionKeepAlive = []byte("connection: keep-alive\r\n")
@@ -0,0 +1,120 @@
+package httptest
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Assertions report failures of chained checks on a recorded response, e.g.
+//
+//	httptest.Record(router.Handler(), req).Assert(t).
+//		Status(http.StatusCreated).
+//		Header("Content-Type", "application/json").
+//		JSON(map[string]any{"id": 1})
+type Assertions struct {
+	t   testing.TB
+	rec *Recorder
+}
+
+func (rec *Recorder) Assert(t testing.TB) *Assertions {
+	return &Assertions{t: t, rec: rec}
+}
+
+func (a *Assertions) Status(expected uint16) *Assertions {
+	a.t.Helper()
+
+	if a.rec.Status != expected {
+		a.t.Errorf("Expected status %d, got %d", expected, a.rec.Status)
+	}
+	return a
+}
+
+func (a *Assertions) Header(name, expected string) *Assertions {
+	a.t.Helper()
+
+	if values := a.rec.HeaderValues(name); len(values) == 0 {
+		a.t.Errorf("Expected header %s: %s, got none", name, expected)
+	} else if values[0] != expected {
+		a.t.Errorf("Expected header %s: %s, got %s", name, expected, values[0])
+	}
+	return a
+}
+
+func (a *Assertions) NoHeader(name string) *Assertions {
+	a.t.Helper()
+
+	if values := a.rec.HeaderValues(name); len(values) > 0 {
+		a.t.Errorf("Expected no header %s, got %v", name, values)
+	}
+	return a
+}
+
+func (a *Assertions) Trailer(name, expected string) *Assertions {
+	a.t.Helper()
+
+	if value := a.rec.Trailer(name); value != expected {
+		a.t.Errorf("Expected trailer %s: %s, got %q", name, expected, value)
+	}
+	return a
+}
+
+func (a *Assertions) Body(expected string) *Assertions {
+	a.t.Helper()
+
+	if string(a.rec.Body) != expected {
+		a.t.Errorf("Expected body %q, got %q", expected, a.rec.Body)
+	}
+	return a
+}
+
+func (a *Assertions) BodyContains(expected string) *Assertions {
+	a.t.Helper()
+
+	if !strings.Contains(string(a.rec.Body), expected) {
+		a.t.Errorf("Expected body to contain %q, got %q", expected, a.rec.Body)
+	}
+	return a
+}
+
+// JSON compares the body to expected after marshalling both to JSON, so field order and
+// number types do not matter
+func (a *Assertions) JSON(expected any) *Assertions {
+	a.t.Helper()
+
+	var got any
+	if err := json.Unmarshal(a.rec.Body, &got); err != nil {
+		a.t.Errorf("Expected JSON body, got %q: %v", a.rec.Body, err)
+		return a
+	}
+
+	raw, err := json.Marshal(expected)
+	if err != nil {
+		a.t.Fatalf("Expected value cannot be marshalled: %v", err)
+	}
+	var want any
+	if err := json.Unmarshal(raw, &want); err != nil {
+		a.t.Fatalf("Expected value cannot be unmarshalled: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		a.t.Errorf("Expected JSON %s, got %s", raw, a.rec.Body)
+	}
+	return a
+}
+
+// Chunks checks the chunks written by a streaming handler
+func (a *Assertions) Chunks(expected ...string) *Assertions {
+	a.t.Helper()
+
+	got := make([]string, len(a.rec.Chunks))
+	for i, chunk := range a.rec.Chunks {
+		got[i] = string(chunk)
+	}
+	if a.rec.Chunks == nil || !slices.Equal(got, expected) {
+		a.t.Errorf("Expected chunks %q, got %q", expected, got)
+	}
+	return a
+}
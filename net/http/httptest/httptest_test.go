@@ -0,0 +1,137 @@
+package httptest_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/freekieb7/gravel/net/http"
+	"github.com/freekieb7/gravel/net/http/httptest"
+)
+
+func TestNewRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "/users?notify=true").
+		Header("X-Request-ID", "abc").
+		Cookie("session", "s1").
+		JSON(map[string]string{"name": "gravel"}).
+		Request()
+
+	if string(req.Method) != "POST" || string(req.Path) != "/users" {
+		t.Errorf("Expected POST /users, got %s %s", req.Method, req.Path)
+	}
+	if notify, _ := req.QueryParam([]byte("notify")); string(notify) != "true" {
+		t.Errorf("Expected query notify=true, got %q", notify)
+	}
+	if host, _ := req.Header([]byte("Host")); string(host) != httptest.DefaultHost {
+		t.Errorf("Expected default host, got %q", host)
+	}
+	if id, _ := req.Header([]byte("X-Request-ID")); string(id) != "abc" {
+		t.Errorf("Expected X-Request-ID abc, got %q", id)
+	}
+	if cookie, err := req.Cookie([]byte("session")); err != nil || cookie.Value != "s1" {
+		t.Errorf("Expected session cookie, got %v %v", cookie, err)
+	}
+	if string(req.Body) != `{"name":"gravel"}` {
+		t.Errorf("Expected JSON body, got %q", req.Body)
+	}
+
+	form := httptest.NewRequest("POST", "/login").Form(url.Values{"user": {"gravel"}}).Request()
+	if user, _ := form.FormValue("user"); user != "gravel" {
+		t.Errorf("Expected form value, got %q", user)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	router := http.NewRouter()
+	router.GET("/users/{id}", func(req *http.Request, res *http.Response) {
+		id, _ := req.Param("id")
+		res.SetCookieValue("a", "1")
+		res.SetCookieValue("b", "2")
+		res.Status = http.StatusCreated
+		res.WithJSON(map[string]any{"id": id, "tags": []string{"x"}})
+	})
+
+	req := httptest.NewRequest("GET", "/users/42").Request()
+	rec := httptest.Record(router.Handler(), req)
+
+	rec.Assert(t).
+		Status(http.StatusCreated).
+		Header("Content-Type", "application/json").
+		NoHeader("Transfer-Encoding").
+		BodyContains(`"id":"42"`).
+		JSON(map[string]any{"tags": []string{"x"}, "id": "42"})
+
+	if cookies := rec.HeaderValues("set-cookie"); len(cookies) != 2 {
+		t.Errorf("Expected 2 cookies, got %v", cookies)
+	}
+	if rec.Response == nil || rec.Response.Status != http.StatusCreated {
+		t.Errorf("Expected the handler response")
+	}
+}
+
+func TestRecordStreaming(t *testing.T) {
+	handler := func(req *http.Request, res *http.Response) {
+		stream, err := res.StartStreaming()
+		if err != nil {
+			t.Fatalf("Streaming failed: %v", err)
+		}
+		_ = stream.WriteString("hello ")
+		_ = stream.WriteString("world")
+		_ = stream.Close()
+	}
+
+	httptest.Record(handler, httptest.NewRequest("GET", "/events").Request()).Assert(t).
+		Status(http.StatusOK).
+		Header("Transfer-Encoding", "chunked").
+		Chunks("hello ", "world").
+		Body("hello world")
+}
+
+func TestRecordWriter(t *testing.T) {
+	handler := func(req *http.Request, res *http.Response) {
+		res.SetHeaderString("Content-Type", "application/json")
+		if err := json.NewEncoder(res).Encode(map[string]int{"count": 3}); err != nil {
+			t.Fatalf("Encoding failed: %v", err)
+		}
+		res.SetTrailer("X-Count", "3")
+	}
+
+	httptest.Record(handler, httptest.NewRequest("GET", "/count").Request()).Assert(t).
+		Status(http.StatusOK).
+		Header("Transfer-Encoding", "chunked").
+		Header("Trailer", "X-Count").
+		Trailer("X-Count", "3").
+		JSON(map[string]int{"count": 3})
+}
+
+func TestServer(t *testing.T) {
+	server := httptest.NewServer(func(req *http.Request, res *http.Response) {
+		res.WithText("echo " + string(req.Body))
+	})
+	defer server.Close()
+
+	rec, err := server.Do(httptest.NewRequest("PUT", "/echo").Text("ping"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	rec.Assert(t).Status(http.StatusOK).Body("echo ping")
+
+	// Keep-alive connection serving two requests
+	conn, err := server.Dial()
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	go func() {
+		_, _ = conn.Write(append(httptest.NewRequest("POST", "/").Text("one").Bytes(), httptest.NewRequest("POST", "/").Text("two").Bytes()...))
+	}()
+
+	br := bufio.NewReader(conn)
+	for _, expected := range []string{"echo one", "echo two"} {
+		rec, err := httptest.ReadResponse(br)
+		if err != nil {
+			t.Fatalf("Reading response failed: %v", err)
+		}
+		rec.Assert(t).Header("Connection", "keep-alive").Body(expected)
+	}
+}
@@ -0,0 +1,194 @@
+package httptest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Recorder is a response as received by the client
+type Recorder struct {
+	Status  uint16
+	Headers []Header
+	// Body of the response, the chunks joined for chunked responses
+	Body []byte
+	// Chunks as written by a streaming handler, nil without chunked encoding
+	Chunks [][]byte
+	// Trailers after the last chunk
+	Trailers []Header
+
+	// Response the handler wrote to, only set by Record
+	Response *http.Response
+}
+
+// Record runs handler for req like the server does and returns the written response. Streaming
+// handlers are supported, it panics when the handler leaves an unreadable response behind
+func Record(handler http.Handler, req *http.Request) *Recorder {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+
+	res := &http.Response{}
+	res.Reset()
+	res.KeepAlive = !req.Close
+	res.SetWriter(bw)
+
+	handler(req, res)
+
+	// Writing to a bytes.Buffer does not fail
+	_ = res.WriteTo(bw)
+	_ = bw.Flush()
+
+	rec, err := ReadResponse(bufio.NewReader(&buf))
+	if err != nil {
+		panic("httptest: handler wrote an incomplete response: " + err.Error())
+	}
+	rec.Response = res
+	return rec
+}
+
+// Header returns the first value of the header matching name (case-insensitive)
+func (rec *Recorder) Header(name string) string {
+	for _, header := range rec.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// HeaderValues returns all values of the headers matching name, e.g. every Set-Cookie
+func (rec *Recorder) HeaderValues(name string) []string {
+	var values []string
+	for _, header := range rec.Headers {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+// Trailer returns the value of the trailer matching name (case-insensitive)
+func (rec *Recorder) Trailer(name string) string {
+	for _, trailer := range rec.Trailers {
+		if strings.EqualFold(trailer.Name, name) {
+			return trailer.Value
+		}
+	}
+	return ""
+}
+
+// ReadResponse reads one HTTP/1.1 response, e.g. from a connection of Server.Dial
+func ReadResponse(br *bufio.Reader) (*Recorder, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol, status, _ := strings.Cut(line, " ")
+	if !strings.HasPrefix(protocol, "HTTP/") {
+		return nil, fmt.Errorf("invalid status line %q", line)
+	}
+	code, _, _ := strings.Cut(status, " ")
+	statusCode, err := strconv.ParseUint(code, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status line %q", line)
+	}
+
+	rec := &Recorder{Status: uint16(statusCode)}
+	if rec.Headers, err = readHeaders(br); err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(rec.Header("Transfer-Encoding"), "chunked") {
+		return rec, readChunks(br, rec)
+	}
+
+	if contentLength := rec.Header("Content-Length"); contentLength != "" {
+		n, err := strconv.Atoi(contentLength)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid content-length %q", contentLength)
+		}
+		rec.Body = make([]byte, n)
+		if _, err := io.ReadFull(br, rec.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	return rec, nil
+}
+
+func readChunks(br *bufio.Reader, rec *Recorder) error {
+	rec.Chunks = [][]byte{}
+
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return err
+		}
+
+		// Chunk extensions are ignored
+		size, _, _ := strings.Cut(line, ";")
+		n, err := strconv.ParseUint(strings.TrimSpace(size), 16, 32)
+		if err != nil {
+			return fmt.Errorf("invalid chunk size %q", line)
+		}
+
+		if n == 0 {
+			// Trailer section ends with an empty line
+			rec.Trailers, err = readHeaders(br)
+			return err
+		}
+
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return err
+		}
+		if line, err := readLine(br); err != nil || line != "" {
+			return errors.New("missing chunk terminator")
+		}
+
+		rec.Chunks = append(rec.Chunks, chunk)
+		rec.Body = append(rec.Body, chunk...)
+	}
+}
+
+func readHeaders(br *bufio.Reader) ([]Header, error) {
+	var headers []Header
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			return headers, nil
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		headers = append(headers, Header{Name: name, Value: strings.TrimSpace(value)})
+	}
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
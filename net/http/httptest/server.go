@@ -0,0 +1,113 @@
+package httptest
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+// Server runs a gravel server on in-memory pipes, connections never touch the network
+type Server struct {
+	// Server under test, configure it before the first request, e.g. its timeouts or hooks
+	Server *http.Server
+
+	listener *pipeListener
+	start    sync.Once
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+// NewServer creates a server for handler, it starts serving on the first Dial
+func NewServer(handler http.Handler) *Server {
+	server := http.NewServer(handler)
+	// A few workers are plenty for tests
+	server.WorkerPoolSize = 4
+
+	return &Server{
+		Server:   &server,
+		listener: &pipeListener{conns: make(chan net.Conn), closed: server.ShutdownCh},
+	}
+}
+
+// Dial opens a client connection, e.g. to send several requests over one keep-alive connection
+func (s *Server) Dial() (net.Conn, error) {
+	s.start.Do(func() {
+		go func() {
+			if err := s.Server.Serve(s.listener); err != nil {
+				s.Server.Logger.Error("httptest server stopped", "error", err)
+			}
+		}()
+	})
+
+	client, server := net.Pipe()
+	select {
+	case s.listener.conns <- server:
+	case <-s.listener.closed:
+		return nil, net.ErrClosed
+	}
+
+	s.mu.Lock()
+	s.conns = append(s.conns, client)
+	s.mu.Unlock()
+	return client, nil
+}
+
+// Do sends the request on a new connection and reads the response
+func (s *Server) Do(b *RequestBuilder) (*Recorder, error) {
+	conn, err := s.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// net.Pipe is synchronous, write while the response is read. The write fails once the
+	// connection closes when the server responds without reading the whole body
+	go func() {
+		_, _ = conn.Write(b.Bytes())
+	}()
+
+	return ReadResponse(bufio.NewReader(conn))
+}
+
+// Close closes the client connections and shuts the server down
+func (s *Server) Close() error {
+	s.mu.Lock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+
+	return s.Server.Shutdown(context.Background())
+}
+
+// pipeListener hands out the server ends of the pipes created by Server.Dial
+type pipeListener struct {
+	conns  chan net.Conn
+	closed <-chan struct{}
+}
+
+func (ln *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ln.conns:
+		return conn, nil
+	case <-ln.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (ln *pipeListener) Close() error {
+	return nil
+}
+
+func (ln *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyRecord is the state of a key, Response is nil while the first request is still running
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *CachedResponse
+}
+
+// IdempotencyStore must make Begin atomic, only one request may claim a key
+type IdempotencyStore interface {
+	// Begin claims key for a new request, or returns the existing record when the key was claimed before
+	Begin(key, fingerprint string, ttl time.Duration) (record *IdempotencyRecord, claimed bool, err error)
+	// Complete stores the response of the request which claimed key
+	Complete(key string, response *CachedResponse, ttl time.Duration) error
+	// Release frees a claimed key without a response, so the request can be retried
+	Release(key string) error
+}
+
+type IdempotencyConfig struct {
+	// Defaults to a new MemoryIdempotencyStore
+	Store IdempotencyStore
+
+	// How long responses are replayed, defaults to 24 hours
+	TTL time.Duration
+
+	// Reject unsafe requests without a key with 400
+	Required bool
+}
+
+// IdempotencyMiddleware replays the first response to requests retried with the same Idempotency-Key.
+// Keys are scoped to the authenticated principal, so run it after the authentication middleware.
+// Server errors are not stored, a retry runs the handler again
+func IdempotencyMiddleware(config IdempotencyConfig) Middleware {
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			if isSafeMethod(req.Method) {
+				next(req, res)
+				return
+			}
+
+			idempotencyKey, found := req.Header([]byte(IdempotencyKeyHeader))
+			if !found || len(idempotencyKey) == 0 {
+				if config.Required {
+					res.Status = StatusBadRequest
+					res.WithText("missing " + IdempotencyKeyHeader + " header")
+					return
+				}
+				next(req, res)
+				return
+			}
+			if len(idempotencyKey) > 255 {
+				res.Status = StatusBadRequest
+				res.WithText("invalid " + IdempotencyKeyHeader + " header")
+				return
+			}
+
+			key := string(idempotencyKey)
+			if principal, found := GetPrincipal(req); found {
+				key = principal.Scheme + ":" + principal.ID + "\n" + key
+			}
+
+			fingerprint := idempotencyFingerprint(req)
+			record, claimed, err := config.Store.Begin(key, fingerprint, config.TTL)
+			if err != nil {
+				RequestLogger(req).Error("idempotency store unavailable", "error", err)
+				res.Status = StatusServiceUnavailable
+				return
+			}
+
+			if !claimed {
+				switch {
+				case record.Fingerprint != fingerprint:
+					res.Status = StatusUnprocessableEntity
+					res.WithText(IdempotencyKeyHeader + " was used for a different request")
+				case record.Response == nil:
+					res.Status = StatusConflict
+					res.ReplaceHeaderString("Retry-After", "1")
+					res.WithText("a request with this " + IdempotencyKeyHeader + " is in progress")
+				default:
+					res.Status = record.Response.Status
+					res.Body = record.Response.Body
+					applyCachedHeaders(res, record.Response.Headers)
+					res.ReplaceHeaderString("Idempotent-Replayed", "true")
+				}
+				return
+			}
+
+			completed := false
+			defer func() {
+				// Free the key after a server error or panic, so a retry runs the handler again
+				if !completed {
+					if err := config.Store.Release(key); err != nil {
+						RequestLogger(req).Error("idempotency key not released", "error", err)
+					}
+				}
+			}()
+
+			start := res.headerCount
+			next(req, res)
+
+			if res.Status >= StatusInternalServerError || res.Chunked || res.streamed > 0 {
+				return
+			}
+
+			if err := config.Store.Complete(key, captureResponse(res, start), config.TTL); err != nil {
+				RequestLogger(req).Error("idempotent response not stored", "error", err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+// idempotencyFingerprint identifies the request, a key reused with another fingerprint is rejected
+func idempotencyFingerprint(req *Request) string {
+	hash := sha256.New()
+	hash.Write(req.Method)
+	hash.Write([]byte{0})
+	hash.Write([]byte(responseCacheKey(req)))
+	hash.Write([]byte{0})
+	hash.Write(req.Body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// MemoryIdempotencyStore keeps keys in memory, keys are not shared between processes
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+	now     func() time.Time
+
+	lastSweep time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]*memoryIdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (store *MemoryIdempotencyStore) Begin(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := store.now()
+	if existing, found := store.records[key]; found && now.Before(existing.expiresAt) {
+		record := existing.record
+		return &record, false, nil
+	}
+
+	if now.Sub(store.lastSweep) > time.Minute {
+		store.lastSweep = now
+		for k, existing := range store.records {
+			if !now.Before(existing.expiresAt) {
+				delete(store.records, k)
+			}
+		}
+	}
+
+	store.records[key] = &memoryIdempotencyRecord{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+func (store *MemoryIdempotencyStore) Complete(key string, response *CachedResponse, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if existing, found := store.records[key]; found {
+		existing.record.Response = response
+		existing.expiresAt = store.now().Add(ttl)
+	}
+	return nil
+}
+
+func (store *MemoryIdempotencyStore) Release(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.records, key)
+	return nil
+}
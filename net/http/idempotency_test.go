@@ -0,0 +1,112 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(IdempotencyConfig{})(func(req *Request, res *Response) {
+		calls++
+		res.Status = StatusCreated
+		res.SetHeaderString("Location", "/payments/1")
+		res.WithText("charged")
+	})
+
+	payment := "POST /payments HTTP/1.1\r\nIdempotency-Key: abc\r\nContent-Length: 9\r\n\r\namount=10"
+
+	first := serveCacheTest(t, handler, payment)
+	retry := serveCacheTest(t, handler, payment)
+
+	if calls != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", calls)
+	}
+	if retry.Status != StatusCreated || string(retry.Body) != string(first.Body) {
+		t.Errorf("Expected replayed response, got %d %q", retry.Status, retry.Body)
+	}
+	if location, _ := retry.Header([]byte("Location")); string(location) != "/payments/1" {
+		t.Errorf("Expected replayed headers, got %q", location)
+	}
+	if replayed, _ := retry.Header([]byte("Idempotent-Replayed")); string(replayed) != "true" {
+		t.Errorf("Expected replay marker, got %q", replayed)
+	}
+
+	mismatch := serveCacheTest(t, handler, "POST /payments HTTP/1.1\r\nIdempotency-Key: abc\r\nContent-Length: 9\r\n\r\namount=99")
+	if mismatch.Status != StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a different body, got %d", mismatch.Status)
+	}
+
+	serveCacheTest(t, handler, "POST /payments HTTP/1.1\r\nIdempotency-Key: other\r\nContent-Length: 9\r\n\r\namount=10")
+	serveCacheTest(t, handler, "POST /payments HTTP/1.1\r\nContent-Length: 9\r\n\r\namount=10")
+	if calls != 3 {
+		t.Errorf("Expected new and missing keys to reach the handler, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	var inner *Response
+	handler := IdempotencyMiddleware(IdempotencyConfig{Store: store})(func(req *Request, res *Response) {
+		// A duplicate arriving while the first request runs
+		inner = serveCacheTest(t, IdempotencyMiddleware(IdempotencyConfig{Store: store})(func(req *Request, res *Response) {
+			t.Error("Expected duplicate not to reach the handler")
+		}), "POST /orders HTTP/1.1\r\nIdempotency-Key: k\r\n\r\n")
+	})
+
+	serveCacheTest(t, handler, "POST /orders HTTP/1.1\r\nIdempotency-Key: k\r\n\r\n")
+	if inner.Status != StatusConflict {
+		t.Errorf("Expected 409 for a concurrent duplicate, got %d", inner.Status)
+	}
+}
+
+func TestIdempotencyReleasesFailures(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(IdempotencyConfig{Required: true})(func(req *Request, res *Response) {
+		calls++
+		if calls == 1 {
+			res.Status = StatusBadGateway
+		}
+	})
+
+	request := "POST /orders HTTP/1.1\r\nIdempotency-Key: k\r\n\r\n"
+	serveCacheTest(t, handler, request)
+	if res := serveCacheTest(t, handler, request); res.Status != StatusOK || calls != 2 {
+		t.Errorf("Expected retry after a server error to run the handler, got %d after %d calls", res.Status, calls)
+	}
+
+	if res := serveCacheTest(t, handler, "POST /orders HTTP/1.1\r\n\r\n"); res.Status != StatusBadRequest {
+		t.Errorf("Expected 400 for a missing required key, got %d", res.Status)
+	}
+}
+
+func TestIdempotencyScopedToPrincipal(t *testing.T) {
+	calls := 0
+	idempotent := IdempotencyMiddleware(IdempotencyConfig{})(func(req *Request, res *Response) {
+		calls++
+	})
+
+	for _, user := range []string{"alice", "bob"} {
+		handler := func(req *Request, res *Response) {
+			SetPrincipal(req, Principal{ID: user, Scheme: "basic"})
+			idempotent(req, res)
+		}
+		serveCacheTest(t, handler, "POST /orders HTTP/1.1\r\nIdempotency-Key: shared\r\n\r\n")
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected keys of different principals not to collide, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyNotReplayedAcrossHosts(t *testing.T) {
+	handler := IdempotencyMiddleware(IdempotencyConfig{})(func(req *Request, res *Response) {
+		res.WithText("charged " + req.Host())
+	})
+
+	serveCacheTest(t, handler, "POST /payments HTTP/1.1\r\nHost: tenant-a.example.com\r\nIdempotency-Key: abc\r\n\r\n")
+	other := serveCacheTest(t, handler, "POST /payments HTTP/1.1\r\nHost: tenant-b.example.com\r\nIdempotency-Key: abc\r\n\r\n")
+
+	if other.Status != StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a key reused on another host, got %d %q", other.Status, other.Body)
+	}
+}
@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+)
+
+// listenReusePort opens n listeners on addr with SO_REUSEPORT, the kernel balances new connections over them
+func listenReusePort(addr string, n int) ([]net.Listener, error) {
+	config := net.ListenConfig{Control: reusePortControl}
+
+	lns := make([]net.Listener, 0, n)
+	for range n {
+		ln, err := config.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+			return nil, err
+		}
+
+		// A random port (":0") is shared by the other listeners
+		addr = ln.Addr().String()
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+// UnixSocketConfig sets the permissions of the socket file created by ListenAndServeUnix
+type UnixSocketConfig struct {
+	// Permissions of the socket file, defaults to 0660 so only the owner and group can connect
+	Mode os.FileMode
+	// Owner and group of the socket file as a name or numeric id, e.g. the group of nginx, unchanged when empty
+	User  string
+	Group string
+}
+
+// ListenAndServeUnix serves on a Unix domain socket at path, e.g. behind a local reverse proxy.
+// A socket file left behind by a crashed process is removed, the file is removed again on shutdown.
+// After an Upgrade the new process serves the socket of the parent instead
+func (s *Server) ListenAndServeUnix(path string, config UnixSocketConfig) error {
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+	if len(inherited) > 0 {
+		for _, ln := range inherited {
+			// The socket file is ours now
+			if unixLn, ok := ln.(*net.UnixListener); ok {
+				unixLn.SetUnlinkOnClose(true)
+			}
+		}
+		return s.serveListeners(inherited, upgradeReady(len(inherited)))
+	}
+
+	ln, err := listenUnix(path, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ln)
+}
+
+func listenUnix(path string, config UnixSocketConfig) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setSocketPermissions(path, config); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes a socket file nobody listens on, a socket in use or another file is an error
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("http: %s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("http: socket %s is in use", path)
+	}
+
+	return os.Remove(path)
+}
+
+func setSocketPermissions(path string, config UnixSocketConfig) error {
+	mode := config.Mode
+	if mode == 0 {
+		mode = 0o660
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	if config.User == "" && config.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if config.User != "" {
+		id, err := lookupID(config.User, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return err
+		}
+		uid = id
+	}
+	if config.Group != "" {
+		id, err := lookupID(config.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return err
+		}
+		gid = id
+	}
+
+	return os.Chown(path, uid, gid)
+}
+
+// lookupID resolves a user or group name, numeric ids are used as is
+func lookupID(name string, lookup func(name string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// SystemdListeners returns the sockets passed by systemd socket activation in the order of the
+// socket unit, nil when the process was not socket activated. Serve them with ServeListeners
+//
+//	lns, err := http.SystemdListeners()
+//	if err != nil {
+//		return err
+//	}
+//	if lns == nil {
+//		return server.ListenAndServe(addr)
+//	}
+//	return server.ServeListeners(lns)
+func SystemdListeners() ([]net.Listener, error) {
+	pid, found := os.LookupEnv("LISTEN_PID")
+	if !found || pid != strconv.Itoa(os.Getpid()) {
+		// Not activated, or the sockets are meant for another process
+		return nil, nil
+	}
+	fds := os.Getenv("LISTEN_FDS")
+
+	// Child processes must not take the sockets
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("http: invalid LISTEN_FDS %q", fds)
+	}
+	return fileListeners(n)
+}
+
+// fileListeners creates listeners from the n descriptors passed from descriptor 3 on, both by
+// systemd and Upgrade. The descriptors are closed, the listeners use duplicates
+func fileListeners(n int) ([]net.Listener, error) {
+	lns := make([]net.Listener, 0, n)
+	for i := range n {
+		f := os.NewFile(uintptr(3+i), "listener")
+		ln, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
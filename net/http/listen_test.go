@@ -0,0 +1,254 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func getUnixTestBody(t *testing.T, path string) string {
+	t.Helper()
+
+	client := http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	resp, err := client.Get("http://gravel/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestServerListenAndServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gravel.sock")
+
+	// Socket file of a crashed process
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("unix " + GetClientIP(req))
+	})
+	s.WorkerPoolSize = 4
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.ListenAndServeUnix(path, UnixSocketConfig{Mode: 0o600, User: strconv.Itoa(os.Getuid())})
+	}()
+
+	var info fs.FileInfo
+	for range 100 {
+		if info, err = os.Stat(path); err == nil && info.Mode().Perm() == 0o600 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info == nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("Expected socket with mode 0600, got %v", info)
+	}
+
+	if body := getUnixTestBody(t, path); body != "unix unknown" {
+		t.Errorf("Expected unix response, got %q", body)
+	}
+
+	// A socket in use is not taken over
+	other := NewServer(nil)
+	if err := other.ListenAndServeUnix(path, UnixSocketConfig{}); err == nil {
+		t.Errorf("Expected error for a socket in use")
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("ListenAndServeUnix failed: %v", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("Expected socket file to be removed on shutdown")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	_ = os.WriteFile(file, nil, 0o600)
+	if err := s.ListenAndServeUnix(file, UnixSocketConfig{}); err == nil {
+		t.Errorf("Expected error for a regular file")
+	}
+}
+
+// TestServerUpgradeUnixChild is the new process started by TestServerUpgradeUnix
+func TestServerUpgradeUnixChild(t *testing.T) {
+	path := os.Getenv("GRAVEL_TEST_UPGRADE_UNIX_CHILD")
+	if path == "" {
+		t.Skip("started by TestServerUpgradeUnix")
+	}
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("child")
+	})
+	s.WorkerPoolSize = 4
+	if err := s.ListenAndServeUnix(path, UnixSocketConfig{}); err != nil {
+		t.Fatalf("ListenAndServeUnix failed: %v", err)
+	}
+}
+
+func TestServerUpgradeUnix(t *testing.T) {
+	if os.Getenv("GRAVEL_TEST_UPGRADE_UNIX_CHILD") != "" {
+		t.Skip("running as the new process")
+	}
+
+	path := filepath.Join(t.TempDir(), "gravel.sock")
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("parent")
+	})
+	s.WorkerPoolSize = 4
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	go func() {
+		if err := s.ListenAndServeUnix(path, UnixSocketConfig{}); err != nil {
+			t.Errorf("ListenAndServeUnix failed: %v", err)
+		}
+	}()
+	for range 100 {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if body := getUnixTestBody(t, path); body != "parent" {
+		t.Fatalf("Expected parent, got %q", body)
+	}
+
+	// The new process runs only the child test
+	args := os.Args
+	os.Args = []string{os.Args[0], "-test.run=^TestServerUpgradeUnixChild$"}
+	defer func() { os.Args = args }()
+	t.Setenv("GRAVEL_TEST_UPGRADE_UNIX_CHILD", path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	process, err := s.Upgrade(ctx)
+	if err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	defer func() {
+		_ = process.Kill()
+		_, _ = process.Wait()
+	}()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	// The socket file survives the shutdown of the parent
+	if body := getUnixTestBody(t, path); body != "child" {
+		t.Errorf("Expected child, got %q", body)
+	}
+}
+
+func TestServerUpgradeUnixFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gravel.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second listener cannot be passed, so the upgrade fails after the first was handed over
+	s := NewServer(nil)
+	s.listeners = []net.Listener{ln, &fakeListener{}}
+	if _, err := s.Upgrade(context.Background()); err == nil {
+		t.Fatal("Expected upgrade to fail")
+	}
+
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected socket file to be removed on close, got %v", err)
+	}
+}
+
+// TestSystemdListenersChild is the socket activated process started by TestSystemdListeners
+func TestSystemdListenersChild(t *testing.T) {
+	if os.Getenv("GRAVEL_TEST_SYSTEMD_CHILD") != "1" {
+		t.Skip("started by TestSystemdListeners")
+	}
+
+	// systemd sets the pid between fork and exec
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	lns, err := SystemdListeners()
+	if err != nil || len(lns) != 1 {
+		t.Fatalf("Expected a listener, got %v %v", lns, err)
+	}
+	if _, found := os.LookupEnv("LISTEN_FDS"); found {
+		t.Fatalf("Expected LISTEN_FDS to be unset")
+	}
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("activated")
+	})
+	s.WorkerPoolSize = 4
+	if err := s.ServeListeners(lns); err != nil {
+		t.Fatalf("ServeListeners failed: %v", err)
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	if os.Getenv("GRAVEL_TEST_SYSTEMD_CHILD") == "1" {
+		t.Skip("running as the activated process")
+	}
+
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	if lns, err := SystemdListeners(); lns != nil || err != nil {
+		t.Errorf("Expected no listeners for another pid, got %v %v", lns, err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	_ = ln.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemdListenersChild$")
+	cmd.Env = append(os.Environ(), "GRAVEL_TEST_SYSTEMD_CHILD=1", "LISTEN_FDS=1")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_ = f.Close()
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	// The socket accepts connections before the process serves them
+	if body := getTestBody(t, ln.Addr().String()); body != "activated" {
+		t.Errorf("Expected activated, got %q", body)
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/freekieb7/gravel/metrics"
+)
+
+// ServerMetrics instruments the accept loop and connection workers of a Server
+type ServerMetrics struct {
+	activeConnections   metrics.Gauge
+	acceptedConnections metrics.Counter
+	droppedConnections  metrics.Counter
+	keepAliveRequests   metrics.Counter
+	requests            metrics.Counter
+	parseErrors         metrics.Counter
+
+	workerChannels atomic.Pointer[[]chan net.Conn]
+}
+
+func NewServerMetrics(registry *metrics.Registry) *ServerMetrics {
+	m := &ServerMetrics{
+		activeConnections:   registry.Gauge("gravel_connections_active", "Connections currently being served."),
+		acceptedConnections: registry.Counter("gravel_connections_accepted_total", "Connections accepted by the listener."),
+		droppedConnections:  registry.Counter("gravel_connections_dropped_total", "Connections closed because all workers were busy."),
+		keepAliveRequests:   registry.Counter("gravel_keepalive_requests_total", "Requests served on a reused keep-alive connection."),
+		requests:            registry.Counter("gravel_requests_total", "Requests parsed successfully."),
+		parseErrors:         registry.Counter("gravel_request_parse_errors_total", "Requests rejected because they could not be parsed."),
+	}
+
+	// Thousands of workers by default, so expose the aggregate instead of a series per worker
+	registry.GaugeFunc("gravel_worker_queue_depth", "Connections waiting in worker queues.", func() float64 {
+		depth, _ := m.queueDepth()
+		return float64(depth)
+	})
+	registry.GaugeFunc("gravel_worker_queue_depth_max", "Connections waiting in the fullest worker queue.", func() float64 {
+		_, maxDepth := m.queueDepth()
+		return float64(maxDepth)
+	})
+	registry.GaugeFunc("gravel_worker_pool_size", "Number of connection workers.", func() float64 {
+		channels := m.workerChannels.Load()
+		if channels == nil {
+			return 0
+		}
+		return float64(len(*channels))
+	})
+
+	return m
+}
+
+func (m *ServerMetrics) queueDepth() (total int, maxDepth int) {
+	channels := m.workerChannels.Load()
+	if channels == nil {
+		return 0, 0
+	}
+
+	for _, ch := range *channels {
+		depth := len(ch)
+		total += depth
+		maxDepth = max(maxDepth, depth)
+	}
+	return total, maxDepth
+}
+
+// MetricsHandler serves the registry in the Prometheus text format
+func MetricsHandler(registry *metrics.Registry) Handler {
+	return func(req *Request, res *Response) {
+		var buf bytes.Buffer
+		if err := registry.WriteText(&buf); err != nil {
+			res.Status = StatusInternalServerError
+			return
+		}
+
+		res.Body = buf.Bytes()
+		res.ReplaceHeaderString("content-type", metrics.ContentType)
+	}
+}
+
+// MetricsMiddleware records request latency per method, route pattern and status
+func MetricsMiddleware(registry *metrics.Registry) Middleware {
+	durations := registry.HistogramVec("gravel_http_request_duration_seconds", "Latency of handled requests.", metrics.DefBuckets, "method", "route", "status")
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			start := time.Now()
+
+			next(req, res)
+
+			// Use the registered pattern, raw paths would explode the number of series
+			route := req.Route()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			durations.WithLabelValues(string(req.Method), route, strconv.Itoa(int(res.Status))).Observe(time.Since(start).Seconds())
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/freekieb7/gravel/metrics"
+)
+
+func TestServerMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	router := NewRouter()
+	router.GET("/users", func(req *Request, res *Response) {
+		res.WithText("users")
+	})
+	router.GET("/metrics", MetricsHandler(registry))
+
+	s := NewServer(MetricsMiddleware(registry)(router.Handler()))
+	s.Logger = slog.New(slog.DiscardHandler)
+	s.Metrics = NewServerMetrics(registry)
+
+	serveTestConn(t, &s, "GET /users HTTP/1.1\r\n\r\nGET /missing HTTP/1.1\r\n\r\nBROKEN\r\n\r\n")
+	out := serveTestConn(t, &s, "GET /metrics HTTP/1.1\r\nConnection: close\r\n\r\n")
+
+	expected := []string{
+		"content-type: " + metrics.ContentType,
+		"gravel_connections_active 1\n", // The connection serving /metrics
+		"gravel_requests_total 3\n",
+		"gravel_keepalive_requests_total 1\n",
+		"gravel_request_parse_errors_total 1\n",
+		`gravel_http_request_duration_seconds_count{method="GET",route="/users",status="200"} 1`,
+		`gravel_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("Expected metrics output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"time"
@@ -16,21 +17,26 @@ var (
 	SessionID = []byte("SID")
 )
 
+const sessionValueKey = "gravel.session"
+
 type Middleware func(next Handler) Handler
 
+// RecoverMiddleware turns a panic into a 500 rendered by DefaultErrorRenderer, the panic is logged with its stack
 func RecoverMiddleware() Middleware {
 	return func(next Handler) Handler {
 		return func(req *Request, res *Response) {
 			defer func() {
-				if recover := recover(); recover != nil {
-					slog.Error("recovered from panic", "error", recover)
-					res.Status = StatusInternalServerError
+				if recovered := recover(); recovered != nil {
 					res.SetHeader([]byte("Cache-Control"), []byte("no-cache, no-store, must-revalidate"))
 					res.SetHeader([]byte("Pragma"), []byte("no-cache"))
 					res.SetHeader([]byte("Expires"), []byte("0"))
 
-					res.WithText("something went wrong")
-					return
+					// RenderError logs the stack, which still contains the panicking frames here
+					err, ok := recovered.(error)
+					if !ok {
+						err = fmt.Errorf("%v", recovered)
+					}
+					RenderError(req, res, &HTTPError{Status: StatusInternalServerError, Err: fmt.Errorf("panic: %w", err)})
 				}
 			}()
 
@@ -95,6 +101,8 @@ func SessionMiddleware() Middleware {
 				sess.Replace(attributes)
 			}
 
+			req.SetValue(sessionValueKey, sess)
+
 			next(req, res)
 
 			if err := sessionStore.Save(sess); err != nil {
@@ -103,3 +111,14 @@ func SessionMiddleware() Middleware {
 		}
 	}
 }
+
+// GetSession returns the session loaded by SessionMiddleware
+func GetSession(req *Request) (session.Session, bool) {
+	value, found := req.Value(sessionValueKey)
+	if !found {
+		return nil, false
+	}
+
+	sess, ok := value.(session.Session)
+	return sess, ok
+}
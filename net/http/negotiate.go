@@ -0,0 +1,187 @@
+package http
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AcceptRange is one element of an Accept, Accept-Language or Accept-Charset header
+type AcceptRange struct {
+	Value string
+	Q     float64
+}
+
+// ParseAccept parses a comma separated list with q-values, sorted by descending quality.
+// Parameters other than q are dropped and elements with an invalid q are skipped
+func ParseAccept(header []byte) []AcceptRange {
+	var ranges []AcceptRange
+	for element := range bytes.SplitSeq(header, []byte(",")) {
+		value, params, _ := bytes.Cut(element, []byte(";"))
+		value = bytes.TrimSpace(value)
+		if len(value) == 0 {
+			continue
+		}
+
+		q, valid := 1.0, true
+		for param := range bytes.SplitSeq(params, []byte(";")) {
+			name, argument, _ := bytes.Cut(param, []byte("="))
+			if !bytes.EqualFold(bytes.TrimSpace(name), []byte("q")) {
+				continue
+			}
+
+			parsed, err := strconv.ParseFloat(string(bytes.TrimSpace(argument)), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				valid = false
+			}
+			q = parsed
+		}
+		if !valid {
+			continue
+		}
+
+		ranges = append(ranges, AcceptRange{Value: strings.ToLower(string(value)), Q: q})
+	}
+
+	slices.SortStableFunc(ranges, func(a, b AcceptRange) int {
+		switch {
+		case a.Q > b.Q:
+			return -1
+		case a.Q < b.Q:
+			return 1
+		}
+		return 0
+	})
+	return ranges
+}
+
+// NegotiateContentType returns the offered media type the client prefers, ties go to the earliest offer.
+// Without an Accept header the first offer is returned
+func NegotiateContentType(req *Request, offers ...string) (string, bool) {
+	return negotiate(req, "accept", offers, mediaRangeSpecificity)
+}
+
+// NegotiateLanguage returns the offered language tag the client prefers, "en" matches an offered "en-GB"
+func NegotiateLanguage(req *Request, offers ...string) (string, bool) {
+	return negotiate(req, "accept-language", offers, languageRangeSpecificity)
+}
+
+// NegotiateCharset returns the offered charset the client prefers
+func NegotiateCharset(req *Request, offers ...string) (string, bool) {
+	return negotiate(req, "accept-charset", offers, func(acceptRange, offer string) int {
+		switch acceptRange {
+		case "*":
+			return 0
+		case offer:
+			return 1
+		}
+		return -1
+	})
+}
+
+// Negotiate calls the renderer of the media type the client prefers, e.g.
+//
+//	res.Negotiate(req, map[string]func(){
+//		"application/json": func() { res.WithJSON(user) },
+//		"text/html":        func() { res.WithHTML(page) },
+//	})
+//
+// Ties, including a missing Accept header, go to the alphabetically first type. When nothing is
+// acceptable it responds 406 with the available types and returns false
+func (res *Response) Negotiate(req *Request, offers map[string]func()) bool {
+	types := slices.Sorted(maps.Keys(offers))
+	addVary(res, "Accept")
+
+	contentType, found := NegotiateContentType(req, types...)
+	if !found {
+		res.Status = StatusNotAcceptable
+		res.WithText("acceptable types: " + strings.Join(types, ", "))
+		return false
+	}
+
+	offers[contentType]()
+	return true
+}
+
+// negotiate picks the offer with the highest quality, taken from the most specific matching range.
+// specificity returns -1 when the range does not match the offer
+func negotiate(req *Request, header string, offers []string, specificity func(acceptRange, offer string) int) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+
+	value, found := req.Header([]byte(header))
+	if !found || len(bytes.TrimSpace(value)) == 0 {
+		return offers[0], true
+	}
+	ranges := ParseAccept(value)
+
+	best, bestQ := -1, 0.0
+	for i, offer := range offers {
+		offer = strings.ToLower(offer)
+
+		q, mostSpecific := 0.0, -1
+		for _, acceptRange := range ranges {
+			if s := specificity(acceptRange.Value, offer); s > mostSpecific {
+				q, mostSpecific = acceptRange.Q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	if best < 0 {
+		return "", false
+	}
+	return offers[best], true
+}
+
+// mediaRangeSpecificity ranks */* below type/* below type/subtype, offer parameters are ignored
+func mediaRangeSpecificity(acceptRange, offer string) int {
+	offer, _, _ = strings.Cut(offer, ";")
+	offerType, offerSubtype, _ := strings.Cut(strings.TrimSpace(offer), "/")
+	rangeType, rangeSubtype, _ := strings.Cut(acceptRange, "/")
+
+	switch {
+	case rangeType == "*" && rangeSubtype == "*":
+		return 0
+	case rangeType != offerType:
+		return -1
+	case rangeSubtype == "*":
+		return 1
+	case rangeSubtype == offerSubtype:
+		return 2
+	}
+	return -1
+}
+
+// languageRangeSpecificity implements RFC 4647 basic filtering, longer ranges are more specific
+func languageRangeSpecificity(acceptRange, offer string) int {
+	switch {
+	case acceptRange == "*":
+		return 0
+	case acceptRange == offer || strings.HasPrefix(offer, acceptRange+"-"):
+		return len(acceptRange)
+	}
+	return -1
+}
+
+// addVary adds a request header to Vary, keeping the names already listed
+func addVary(res *Response, name string) {
+	vary, found := res.Header([]byte("vary"))
+	if !found || len(vary) == 0 {
+		res.ReplaceHeaderString("Vary", name)
+		return
+	}
+
+	for existing := range bytes.SplitSeq(vary, []byte(",")) {
+		if bytes.EqualFold(bytes.TrimSpace(existing), []byte(name)) {
+			return
+		}
+	}
+	res.ReplaceHeaderString("Vary", string(vary)+", "+name)
+}
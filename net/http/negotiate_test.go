@@ -0,0 +1,96 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	ranges := ParseAccept([]byte("text/html;level=1, application/json;q=0.9, */*;q=0.1, text/plain;q=2"))
+
+	expected := []AcceptRange{{"text/html", 1}, {"application/json", 0.9}, {"*/*", 0.1}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("Expected %v at %d, got %v", expected[i], i, ranges[i])
+		}
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected string
+		found    bool
+	}{
+		{"", "application/json", true},
+		{"application/xml", "application/xml", true},
+		{"text/*;q=0.5, application/json;q=0.4", "text/html", true},
+		{"*/*;q=0.1, application/xml", "application/xml", true},
+		{"application/*, application/json;q=0", "application/xml", true},
+		{"image/png", "", false},
+	}
+
+	for _, tt := range tests {
+		raw := "GET / HTTP/1.1\r\n"
+		if tt.accept != "" {
+			raw += "Accept: " + tt.accept + "\r\n"
+		}
+		req := parseTestRequest(t, raw+"\r\n")
+
+		got, found := NegotiateContentType(req, "application/json", "application/xml", "text/html")
+		if got != tt.expected || found != tt.found {
+			t.Errorf("Accept %q: expected %q %v, got %q %v", tt.accept, tt.expected, tt.found, got, found)
+		}
+	}
+}
+
+func TestNegotiateLanguageAndCharset(t *testing.T) {
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nAccept-Language: nl-BE, en;q=0.8, *;q=0.1\r\nAccept-Charset: iso-8859-1;q=0.5, utf-8\r\n\r\n")
+
+	if language, _ := NegotiateLanguage(req, "en-GB", "nl", "de"); language != "en-GB" {
+		t.Errorf("Expected en-GB, got %q", language)
+	}
+	if language, _ := NegotiateLanguage(req, "de", "fr"); language != "de" {
+		t.Errorf("Expected wildcard match de, got %q", language)
+	}
+	if charset, _ := NegotiateCharset(req, "ISO-8859-1", "UTF-8"); charset != "UTF-8" {
+		t.Errorf("Expected UTF-8, got %q", charset)
+	}
+}
+
+func TestResponseNegotiate(t *testing.T) {
+	offers := func(res *Response) map[string]func() {
+		return map[string]func(){
+			"application/json": func() { res.WithJSON(`{"name":"gravel"}`) },
+			"text/html":        func() { res.WithHTML("<b>gravel</b>") },
+		}
+	}
+
+	var res Response
+	res.Reset()
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nAccept: text/html,application/xhtml+xml,*/*;q=0.8\r\n\r\n")
+	if !res.Negotiate(req, offers(&res)) || string(res.Body) != "<b>gravel</b>" {
+		t.Errorf("Expected HTML, got %q", res.Body)
+	}
+	if vary, _ := res.Header([]byte("Vary")); string(vary) != "Accept" {
+		t.Errorf("Expected Vary: Accept, got %q", vary)
+	}
+
+	res.Reset()
+	req = parseTestRequest(t, "GET / HTTP/1.1\r\n\r\n")
+	if !res.Negotiate(req, offers(&res)) || string(res.Body) != `{"name":"gravel"}` {
+		t.Errorf("Expected JSON without Accept, got %q", res.Body)
+	}
+
+	res.Reset()
+	res.SetHeaderString("Vary", "Accept-Language")
+	req = parseTestRequest(t, "GET / HTTP/1.1\r\nAccept: image/webp\r\n\r\n")
+	if res.Negotiate(req, offers(&res)) || res.Status != StatusNotAcceptable {
+		t.Errorf("Expected 406, got %d", res.Status)
+	}
+	if vary, _ := res.Header([]byte("Vary")); string(vary) != "Accept-Language, Accept" {
+		t.Errorf("Expected Accept added to Vary, got %q", vary)
+	}
+}
@@ -0,0 +1,267 @@
+package http
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts up to Requests and refills Requests tokens per Window
+	TokenBucket RateLimitAlgorithm = iota + 1
+	// SlidingWindow weighs the previous fixed window to smooth out boundary bursts
+	SlidingWindow
+)
+
+type RateLimit struct {
+	Algorithm RateLimitAlgorithm
+	Requests  int
+	Window    time.Duration
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Until the limit is fully restored
+	RetryAfter time.Duration // Until the next request is allowed, when denied
+}
+
+// RateLimitStore tracks usage per key, implement it to share limits between instances (e.g. redis)
+type RateLimitStore interface {
+	Allow(key string, limit RateLimit) (RateLimitResult, error)
+}
+
+type RateLimitConfig struct {
+	Limit RateLimit
+
+	// Identifies the client, defaults to RateLimitKeyByIP
+	KeyFunc func(req *Request) string
+
+	// Defaults to a new MemoryRateLimitStore
+	Store RateLimitStore
+
+	// Called when the limit is exceeded, defaults to a plain 429
+	ExceededHandler Handler
+}
+
+// RateLimitKeyByIP limits per client IP
+func RateLimitKeyByIP(req *Request) string {
+	return GetClientIP(req)
+}
+
+// RateLimitKeyByRoute limits per client IP and route pattern, so /users/1 and /users/2 share a limit.
+// The pattern is only known in route middleware, before routing the path is used
+func RateLimitKeyByRoute(req *Request) string {
+	route := req.Route()
+	if route == "" {
+		route = string(req.Path)
+	}
+	return string(req.Method) + " " + route + " " + GetClientIP(req)
+}
+
+// RateLimitKeyByPrincipal limits per authenticated principal, see SetPrincipal, and per client IP
+// for anonymous requests. Run it after the authentication middleware
+func RateLimitKeyByPrincipal(req *Request) string {
+	if principal, found := GetPrincipal(req); found {
+		return principal.Scheme + ":" + principal.ID
+	}
+	return GetClientIP(req)
+}
+
+func RateLimitMiddleware(config RateLimitConfig) Middleware {
+	if config.Limit.Algorithm == 0 {
+		config.Limit.Algorithm = TokenBucket
+	}
+	if config.Limit.Requests == 0 {
+		config.Limit.Requests = 60
+	}
+	if config.Limit.Window == 0 {
+		config.Limit.Window = time.Minute
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitKeyByIP
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	if config.ExceededHandler == nil {
+		config.ExceededHandler = func(req *Request, res *Response) {
+			res.Status = StatusTooManyRequests
+			res.WithText("too many requests")
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			result, err := config.Store.Allow(config.KeyFunc(req), config.Limit)
+			if err != nil {
+				// Fail open, an unavailable store should not take the service down
+				slog.Error("rate limit store error", "error", err)
+				next(req, res)
+				return
+			}
+
+			res.ReplaceHeaderString("RateLimit-Limit", strconv.Itoa(result.Limit))
+			res.ReplaceHeaderString("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			res.ReplaceHeaderString("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				res.ReplaceHeaderString("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+				config.ExceededHandler(req, res)
+				return
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+const (
+	rateLimitShardCount    = 64
+	rateLimitSweepInterval = 1024 // Operations per shard between sweeps of idle entries
+)
+
+type rateLimitEntry struct {
+	// Token bucket state
+	tokens float64
+
+	// Sliding window state
+	windowStart time.Time
+	current     int
+	previous    int
+
+	lastSeen time.Time
+	window   time.Duration
+}
+
+type rateLimitShard struct {
+	mu         sync.Mutex
+	entries    map[string]*rateLimitEntry
+	operations int
+}
+
+// MemoryRateLimitStore is a sharded in-memory store, limits are not shared between processes
+type MemoryRateLimitStore struct {
+	shards [rateLimitShardCount]rateLimitShard
+	now    func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{
+		now: time.Now,
+	}
+	for i := range store.shards {
+		store.shards[i].entries = make(map[string]*rateLimitEntry)
+	}
+	return store
+}
+
+func (store *MemoryRateLimitStore) Allow(key string, limit RateLimit) (RateLimitResult, error) {
+	shard := &store.shards[fnv32(key)%rateLimitShardCount]
+	now := store.now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.operations++
+	if shard.operations%rateLimitSweepInterval == 0 {
+		shard.sweep(now)
+	}
+
+	entry, found := shard.entries[key]
+	if !found {
+		entry = &rateLimitEntry{
+			tokens:      float64(limit.Requests),
+			windowStart: now.Truncate(limit.Window),
+			lastSeen:    now,
+		}
+		shard.entries[key] = entry
+	}
+	entry.window = limit.Window
+
+	if limit.Algorithm == SlidingWindow {
+		return entry.allowSlidingWindow(limit, now), nil
+	}
+	return entry.allowTokenBucket(limit, now), nil
+}
+
+func (entry *rateLimitEntry) allowTokenBucket(limit RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Window.Seconds()
+
+	// Refill for the time passed since the last request
+	entry.tokens = min(capacity, entry.tokens+now.Sub(entry.lastSeen).Seconds()*perSecond)
+	entry.lastSeen = now
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if entry.tokens >= 1 {
+		entry.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - entry.tokens) / perSecond * float64(time.Second))
+	}
+
+	result.Remaining = int(entry.tokens)
+	result.Reset = time.Duration((capacity - entry.tokens) / perSecond * float64(time.Second))
+	return result
+}
+
+func (entry *rateLimitEntry) allowSlidingWindow(limit RateLimit, now time.Time) RateLimitResult {
+	windowStart := now.Truncate(limit.Window)
+	if !windowStart.Equal(entry.windowStart) {
+		if windowStart.Sub(entry.windowStart) == limit.Window {
+			entry.previous = entry.current
+		} else {
+			entry.previous = 0
+		}
+		entry.current = 0
+		entry.windowStart = windowStart
+	}
+	entry.lastSeen = now
+
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+	estimated := float64(entry.previous)*weight + float64(entry.current)
+
+	result := RateLimitResult{
+		Limit: limit.Requests,
+		Reset: limit.Window - elapsed,
+	}
+	if estimated+1 <= float64(limit.Requests) {
+		entry.current++
+		estimated++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = limit.Window - elapsed
+	}
+
+	result.Remaining = max(0, limit.Requests-int(math.Ceil(estimated)))
+	return result
+}
+
+// sweep removes entries which have been idle long enough to be fully restored
+func (shard *rateLimitShard) sweep(now time.Time) {
+	for key, entry := range shard.entries {
+		if now.Sub(entry.lastSeen) > 2*entry.window {
+			delete(shard.entries, key)
+		}
+	}
+}
+
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
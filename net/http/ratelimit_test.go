@@ -0,0 +1,131 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	limit := RateLimit{Algorithm: TokenBucket, Requests: 2, Window: 2 * time.Second}
+
+	for i := range 2 {
+		result, _ := store.Allow("client", limit)
+		if !result.Allowed {
+			t.Fatalf("Request %d should be allowed", i)
+		}
+	}
+
+	result, _ := store.Allow("client", limit)
+	if result.Allowed {
+		t.Fatal("Third request should be denied")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %s", result.RetryAfter)
+	}
+
+	// One token is refilled per second
+	now = now.Add(time.Second)
+	result, _ = store.Allow("client", limit)
+	if !result.Allowed {
+		t.Error("Request should be allowed after refill")
+	}
+
+	// Other keys are unaffected
+	result, _ = store.Allow("other", limit)
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("Expected fresh bucket for other key, got %+v", result)
+	}
+}
+
+func TestRateLimitSlidingWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	limit := RateLimit{Algorithm: SlidingWindow, Requests: 4, Window: 10 * time.Second}
+
+	for range 4 {
+		store.Allow("client", limit)
+	}
+	if result, _ := store.Allow("client", limit); result.Allowed {
+		t.Fatal("Fifth request in window should be denied")
+	}
+
+	// Halfway through the next window half of the previous count still applies
+	now = now.Add(15 * time.Second)
+	allowed := 0
+	for range 4 {
+		if result, _ := store.Allow("client", limit); result.Allowed {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("Expected 2 allowed requests, got %d", allowed)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RateLimitMiddleware(RateLimitConfig{
+		Limit: RateLimit{Requests: 1, Window: time.Minute},
+	})(func(req *Request, res *Response) {
+		res.WithText("ok")
+	})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nX-Real-IP: 10.0.0.1\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.Status)
+	}
+	if remaining, _ := res.Header([]byte("RateLimit-Remaining")); string(remaining) != "0" {
+		t.Errorf("Expected remaining 0, got %s", remaining)
+	}
+
+	res.Reset()
+	handler(req, res)
+	if res.Status != StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", res.Status)
+	}
+	if retryAfter, _ := res.Header([]byte("Retry-After")); string(retryAfter) != "60" {
+		t.Errorf("Expected Retry-After 60, got %s", retryAfter)
+	}
+}
+
+func TestRateLimitKeyByRoute(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/{id}", func(req *Request, res *Response) {}, RateLimitMiddleware(RateLimitConfig{
+		Limit:   RateLimit{Requests: 1, Window: time.Minute},
+		KeyFunc: RateLimitKeyByRoute,
+	}))
+	handler := router.Handler()
+
+	var statuses []uint16
+	for _, path := range []string{"/users/1", "/users/2"} {
+		req := parseTestRequest(t, "GET "+path+" HTTP/1.1\r\nX-Real-IP: 10.0.0.1\r\n\r\n")
+		res := &Response{}
+		res.Reset()
+		handler(req, res)
+		statuses = append(statuses, res.Status)
+	}
+
+	if statuses[0] != StatusOK || statuses[1] != StatusTooManyRequests {
+		t.Errorf("Expected paths of one route to share a limit, got %v", statuses)
+	}
+}
+
+func TestRateLimitKeyByPrincipal(t *testing.T) {
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nX-Real-IP: 10.0.0.1\r\n\r\n")
+	if key := RateLimitKeyByPrincipal(req); key != "10.0.0.1" {
+		t.Errorf("Expected client IP for anonymous requests, got %s", key)
+	}
+
+	SetPrincipal(req, Principal{ID: "user-1", Scheme: "bearer"})
+	if key := RateLimitKeyByPrincipal(req); key != "bearer:user-1" {
+		t.Errorf("Expected principal key, got %s", key)
+	}
+}
@@ -3,8 +3,16 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"io"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/freekieb7/gravel/uuid"
 )
 
 type Request struct {
@@ -14,16 +22,36 @@ type Request struct {
 	Body     []byte
 	Close    bool
 
+	// Address of the connection the request was received on
+	RemoteAddr net.Addr
+
 	// Add buffer to reuse for body reading
 	bodyBuf [4096]byte
 
 	headers     [32]Header // Support up to 32 headers
 	headerCount int
+	// Values too long for Header.Value (e.g. bearer tokens), reused between requests
+	longHeaderValues [32][]byte
 
 	queryParams      [32]QueryParam
 	queryParamsCount int
 
 	lowerKey [64]byte // Pre-allocated buffer for lowercased keys
+
+	// Request scoped values shared between middleware and handlers
+	values map[string]any
+
+	// Context carried to downstream calls, see Context
+	ctx context.Context
+
+	// Pattern of the route matched by the router
+	route string
+	// Path parameters captured by the router, reused between requests
+	params []pathParam
+
+	// Lazily parsed urlencoded form body
+	form       url.Values
+	formParsed bool
 }
 
 func (req *Request) Reset() {
@@ -35,6 +63,144 @@ func (req *Request) Reset() {
 
 	req.headerCount = 0
 	req.queryParamsCount = 0
+
+	// Keep the map allocated for the next request on this connection
+	clear(req.values)
+	req.ctx = nil
+	req.route = ""
+	req.params = req.params[:0]
+	req.form = nil
+	req.formParsed = false
+}
+
+// Context returns the request context, context.Background() unless set by middleware
+func (req *Request) Context() context.Context {
+	if req.ctx == nil {
+		return context.Background()
+	}
+	return req.ctx
+}
+
+// SetContext replaces the request context, e.g. to attach a deadline or trace
+func (req *Request) SetContext(ctx context.Context) {
+	req.ctx = ctx
+}
+
+// Route returns the registered path of the matched route, empty when no route matched
+func (req *Request) Route() string {
+	return req.route
+}
+
+// Host returns the lowercased host the request was sent to without the port, taken from the
+// HTTP/2 :authority pseudo header when present and the Host header otherwise
+func (req *Request) Host() string {
+	value, found := req.Header([]byte(":authority"))
+	if !found {
+		value, _ = req.Header([]byte("host"))
+	}
+
+	host := string(bytes.ToLower(value))
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return host
+}
+
+// Param returns the parameter captured for a {name} or :name segment of the route or a {name} label of the host
+func (req *Request) Param(name string) (string, bool) {
+	for _, param := range req.params {
+		if param.name == name {
+			return param.value, true
+		}
+	}
+	return "", false
+}
+
+// ParamInt parses the path parameter name as an integer, a missing or malformed value is a 400
+// *HTTPError so handlers of HandleErrors can return it as is
+func (req *Request) ParamInt(name string) (int, error) {
+	value, found := req.Param(name)
+	if !found {
+		return 0, NewHTTPError(StatusBadRequest, "missing param "+name)
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &HTTPError{Status: StatusBadRequest, Detail: "param " + name + " must be an integer", Err: err}
+	}
+	return n, nil
+}
+
+// ParamUUID parses the path parameter name as a UUID, a missing or malformed value is a 400 *HTTPError
+func (req *Request) ParamUUID(name string) (uuid.UUID, error) {
+	value, found := req.Param(name)
+	if !found {
+		return uuid.UUID{}, NewHTTPError(StatusBadRequest, "missing param "+name)
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.UUID{}, &HTTPError{Status: StatusBadRequest, Detail: "param " + name + " must be a UUID", Err: err}
+	}
+	return id, nil
+}
+
+// SetValue stores a request scoped value, e.g. the session or a generated token
+func (req *Request) SetValue(key string, value any) {
+	if req.values == nil {
+		req.values = make(map[string]any)
+	}
+	req.values[key] = value
+}
+
+// Value returns a request scoped value set by SetValue
+func (req *Request) Value(key string) (any, bool) {
+	value, found := req.values[key]
+	return value, found
+}
+
+// clone deep copies the request so it can outlive the connection, e.g. for background work.
+// The context is detached from the cancellation of the original request
+func (req *Request) clone() *Request {
+	clone := *req
+
+	clone.Method = bytes.Clone(req.Method)
+	clone.Path = bytes.Clone(req.Path)
+	clone.Protocol = bytes.Clone(req.Protocol)
+	clone.Body = bytes.Clone(req.Body)
+	for i := range clone.longHeaderValues {
+		clone.longHeaderValues[i] = bytes.Clone(req.longHeaderValues[i])
+	}
+
+	clone.params = slices.Clone(req.params)
+	clone.values = maps.Clone(req.values)
+	clone.form = nil
+	clone.formParsed = false
+	clone.ctx = context.WithoutCancel(req.Context())
+
+	return &clone
+}
+
+// FormValue returns the first value of an application/x-www-form-urlencoded body field
+func (req *Request) FormValue(name string) (string, bool) {
+	if !req.formParsed {
+		req.formParsed = true
+
+		contentType, _ := req.Header([]byte("content-type"))
+		if bytes.HasPrefix(contentType, []byte("application/x-www-form-urlencoded")) && len(req.Body) > 0 {
+			form, err := url.ParseQuery(string(req.Body))
+			if err == nil {
+				req.form = form
+			}
+		}
+	}
+
+	values, found := req.form[name]
+	if !found || len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
 }
 
 func (req *Request) QueryParam(name []byte) ([]byte, bool) {
@@ -269,8 +435,7 @@ func (req *Request) parseHeaders(br *bufio.Reader) error {
 			}
 
 			// Store value as-is
-			h.ValueLen = min(len(value), len(h.Value))
-			copy(h.Value[:h.ValueLen], value[:h.ValueLen])
+			req.setHeaderValue(req.headerCount, value)
 
 			req.headerCount++
 		}
@@ -451,13 +616,34 @@ func (req *Request) Header(name []byte) ([]byte, bool) {
 		}
 
 		if match {
-			return h.Value[:h.ValueLen], true
+			return req.headerValue(i), true
 		}
 	}
 
 	return nil, false // Header not found
 }
 
+// setHeaderValue stores value inline, or in the overflow buffer when it does not fit
+func (req *Request) setHeaderValue(i int, value []byte) {
+	h := &req.headers[i]
+	h.ValueLen = len(value)
+
+	if len(value) <= len(h.Value) {
+		copy(h.Value[:], value)
+		return
+	}
+
+	req.longHeaderValues[i] = append(req.longHeaderValues[i][:0], value...)
+}
+
+func (req *Request) headerValue(i int) []byte {
+	h := &req.headers[i]
+	if h.ValueLen > len(h.Value) {
+		return req.longHeaderValues[i][:h.ValueLen]
+	}
+	return h.Value[:h.ValueLen]
+}
+
 func (req *Request) AddCookie(cookie Cookie) {
 	// Get existing Cookie header
 	existingCookie, found := req.Header([]byte("cookie"))
@@ -487,8 +673,7 @@ func (req *Request) setCookieHeader(value []byte) {
 		h := &req.headers[i]
 		if h.NameLen == 6 && bytes.Equal(h.Name[:6], []byte("cookie")) {
 			// Update existing header
-			h.ValueLen = min(len(value), len(h.Value))
-			copy(h.Value[:h.ValueLen], value[:h.ValueLen])
+			req.setHeaderValue(i, value)
 			return
 		}
 	}
@@ -498,8 +683,7 @@ func (req *Request) setCookieHeader(value []byte) {
 		h := &req.headers[req.headerCount]
 		copy(h.Name[:], []byte("cookie"))
 		h.NameLen = 6
-		h.ValueLen = min(len(value), len(h.Value))
-		copy(h.Value[:h.ValueLen], value[:h.ValueLen])
+		req.setHeaderValue(req.headerCount, value)
 		req.headerCount++
 	}
 }
@@ -42,3 +42,24 @@ func BenchmarkRequestParse(b *testing.B) {
 		}
 	}
 }
+
+func TestRequestLongHeader(t *testing.T) {
+	var req Request
+
+	token := bytes.Repeat([]byte("a"), 1200)
+	reqMsg := append(append([]byte("GET /test HTTP/1.1\r\nAuthorization: Bearer "), token...), "\r\nAccept: text/css\r\n\r\n"...)
+
+	if err := req.Parse(bufio.NewReader(bytes.NewBuffer(reqMsg))); err != nil {
+		t.Fatal(err)
+	}
+
+	h, found := req.Header([]byte("authorization"))
+	if !found || !bytes.Equal(h, append([]byte("Bearer "), token...)) {
+		t.Errorf("expected full authorization header, got %d bytes", len(h))
+	}
+
+	h, _ = req.Header([]byte("accept"))
+	if !bytes.Equal(h, []byte("text/css")) {
+		t.Errorf("expected text/css, got %s", h)
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/freekieb7/gravel/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDValueKey     = "gravel.request_id"
+	requestLoggerValueKey = "gravel.logger"
+	requestIDMaxLength    = 128
+)
+
+type requestIDContextKey struct{}
+
+// RequestIDMiddleware reuses a valid incoming X-Request-ID or generates a time-ordered UUID,
+// echoes it on the response and makes it available to handlers, loggers and outgoing calls
+func RequestIDMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			id, found := req.Header([]byte(RequestIDHeader))
+			if !found || !validRequestID(id) {
+				id = []byte(uuid.NewV7().String())
+			}
+			requestID := string(id)
+
+			req.SetValue(requestIDValueKey, requestID)
+			req.SetValue(requestLoggerValueKey, slog.Default().With("request_id", requestID))
+			req.SetContext(ContextWithRequestID(req.Context(), requestID))
+			res.ReplaceHeaderString(RequestIDHeader, requestID)
+
+			next(req, res)
+		}
+	}
+}
+
+// RequestID returns the id assigned by RequestIDMiddleware
+func RequestID(req *Request) string {
+	value, found := req.Value(requestIDValueKey)
+	if !found {
+		return ""
+	}
+
+	id, _ := value.(string)
+	return id
+}
+
+// RequestLogger returns slog.Default() annotated with the request id when available
+func RequestLogger(req *Request) *slog.Logger {
+	if value, found := req.Value(requestLoggerValueKey); found {
+		if logger, ok := value.(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok
+}
+
+// RequestIDTransport forwards the request id found in the outgoing request context,
+// use it as transport of the http.Client used for calls made while handling a request, or
+// pass it to the SetTransport of the oauth clients
+type RequestIDTransport struct {
+	// Defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *RequestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id, found := RequestIDFromContext(r.Context())
+	if !found || r.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(r)
+	}
+
+	// RoundTrippers must not modify the original request
+	r = r.Clone(r.Context())
+	r.Header.Set(RequestIDHeader, id)
+	return base.RoundTrip(r)
+}
+
+// validRequestID accepts ids of printable token characters, so they are safe to log and echo
+func validRequestID(id []byte) bool {
+	if len(id) == 0 || len(id) > requestIDMaxLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
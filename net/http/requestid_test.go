@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freekieb7/gravel/auth/oauth"
+	"github.com/freekieb7/gravel/uuid"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware()(func(req *Request, res *Response) {
+		seen = RequestID(req)
+		if id, _ := RequestIDFromContext(req.Context()); id != seen {
+			t.Errorf("Expected context id %s, got %s", seen, id)
+		}
+	})
+
+	// Valid incoming id is kept
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nX-Request-ID: trace-123\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+	if seen != "trace-123" {
+		t.Errorf("Expected incoming id to be kept, got %s", seen)
+	}
+	if id, _ := res.Header([]byte(RequestIDHeader)); string(id) != "trace-123" {
+		t.Errorf("Expected id on response, got %s", id)
+	}
+
+	// Invalid incoming id is replaced
+	req = parseTestRequest(t, "GET / HTTP/1.1\r\nX-Request-ID: bad id\"\r\n\r\n")
+	res.Reset()
+	handler(req, res)
+	id, err := uuid.Parse(seen)
+	if err != nil || id.Version() != 7 {
+		t.Errorf("Expected generated v7 uuid, got %s", seen)
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(RequestIDHeader)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &RequestIDTransport{}}
+	outgoing, _ := http.NewRequestWithContext(ContextWithRequestID(t.Context(), "abc"), http.MethodGet, server.URL, nil)
+	resp, err := client.Do(outgoing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if received != "abc" {
+		t.Errorf("Expected forwarded id abc, got %q", received)
+	}
+}
+
+func TestRequestIDTransportOAuthClient(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(RequestIDHeader))
+		if r.URL.Path == "/config" {
+			_, _ = w.Write([]byte(`{"jwks_uri":"` + "http://" + r.Host + `/jwks"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer server.Close()
+
+	client := oauth.NewBaseOAuthClient("id", "secret", server.URL+"/token", server.URL+"/config")
+	client.SetTransport(&RequestIDTransport{})
+
+	if _, err := client.GetJWKSContext(ContextWithRequestID(t.Context(), "abc")); err != nil {
+		t.Fatalf("GetJWKSContext failed: %v", err)
+	}
+	if len(received) != 2 || received[0] != "abc" || received[1] != "abc" {
+		t.Errorf("Expected the id forwarded to both calls, got %q", received)
+	}
+}
@@ -2,10 +2,17 @@ package http
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
 )
 
+var errResponseEnded = errors.New("response already ended")
+
 type Response struct {
 	Status      uint16
 	KeepAlive   bool
@@ -14,10 +21,26 @@ type Response struct {
 	headerBuf   [1024]byte
 	headers     [16]Header
 	headerCount int
+	// Headers beyond the inline array, kept between requests to reuse their memory
+	extraHeaders []Header
+	// Values longer than Header.Value, indexed like the headers
+	longHeaderValues [][]byte
 	// Buffer for chunk size hex conversion
 	chunkSizeBuf [16]byte
 	// Add internal writer reference for streaming
 	writer *bufio.Writer
+	// Body bytes sent through the streaming helpers
+	streamed int
+	// Status line and headers are on the wire, the body follows in chunks
+	headerWritten bool
+	// Last chunk written, nothing may follow
+	ended    bool
+	trailers []trailer
+}
+
+type trailer struct {
+	name  string
+	value string
 }
 
 func (res *Response) Reset() {
@@ -28,30 +51,74 @@ func (res *Response) Reset() {
 	res.headerCount = 0
 	res.Chunked = false
 	res.writer = nil // Clear writer reference
+	res.streamed = 0
+	res.headerWritten = false
+	res.ended = false
+	res.trailers = res.trailers[:0]
+}
+
+// BytesWritten returns the number of body bytes sent, or to be sent, for this response
+func (res *Response) BytesWritten() int {
+	return res.streamed + len(res.Body)
 }
 
+// SetHeader adds a response header, a repeated name is sent once per value (e.g. Link). Use
+// ReplaceHeader to overwrite an earlier value
 func (res *Response) SetHeader(name, value []byte) {
-	if res.headerCount >= len(res.headers) {
-		return // Skip if we've reached max headers
+	res.addHeader(name, value)
+}
+
+// ReplaceHeader sets a response header, replacing the value of an earlier header with the same name
+func (res *Response) ReplaceHeader(name, value []byte) {
+	for i := 0; i < res.headerCount; i++ {
+		h := res.header(i)
+		if bytes.EqualFold(h.Name[:h.NameLen], name) {
+			res.setHeaderValue(i, value)
+			return
+		}
 	}
 
-	h := &res.headers[res.headerCount]
+	res.addHeader(name, value)
+}
 
-	// Copy name (truncate if too long)
-	h.NameLen = min(len(name), len(h.Name))
-	copy(h.Name[:h.NameLen], name[:h.NameLen])
+// Header returns the first response header matching name (case-insensitive)
+func (res *Response) Header(name []byte) ([]byte, bool) {
+	for i := 0; i < res.headerCount; i++ {
+		h := res.header(i)
+		if bytes.EqualFold(h.Name[:h.NameLen], name) {
+			return res.headerValue(i), true
+		}
+	}
 
-	// Copy value (truncate if too long)
-	h.ValueLen = min(len(value), len(h.Value))
-	copy(h.Value[:h.ValueLen], value[:h.ValueLen])
+	return nil, false
+}
 
-	res.headerCount++
+// DelHeader removes all response headers matching name (case-insensitive)
+func (res *Response) DelHeader(name []byte) {
+	n := 0
+	for i := 0; i < res.headerCount; i++ {
+		h := res.header(i)
+		if bytes.EqualFold(h.Name[:h.NameLen], name) {
+			continue
+		}
+		if n != i {
+			target := res.header(n)
+			target.NameLen = copy(target.Name[:], h.Name[:h.NameLen])
+			res.setHeaderValue(n, res.headerValue(i))
+		}
+		n++
+	}
+	res.headerCount = n
 }
 
 func (res *Response) SetHeaderString(name, value string) {
 	res.SetHeader([]byte(name), []byte(value))
 }
 
+func (res *Response) ReplaceHeaderString(name, value string) {
+	res.ReplaceHeader([]byte(name), []byte(value))
+}
+
 // SetCookie adds a Set-Cookie header to the response
 func (res *Response) SetCookie(cookie *Cookie) {
 	if cookie == nil {
@@ -63,8 +130,8 @@ func (res *Response) SetCookie(cookie *Cookie) {
 		return // Skip invalid cookies
 	}
 
-	cookieStr := cookie.String()
-	res.SetHeaderString("Set-Cookie", cookieStr)
+	// Every cookie needs its own Set-Cookie header
+	res.addHeader([]byte("Set-Cookie"), []byte(cookie.String()))
 }
 
 // SetCookieValue is a convenience method to set a simple cookie
@@ -90,7 +157,7 @@ func (res *Response) DeleteCookie(name string) {
 
 func (res *Response) WithText(payload string) *Response {
 	res.Body = []byte(payload)
-	res.SetHeaderString("content-type", "text/plain")
+	res.ReplaceHeaderString("content-type", "text/plain")
 	return res
 }
 
@@ -105,21 +172,21 @@ func (res *Response) WithJSON(payload any) *Response {
 		res.Body = data
 	}
 
-	res.SetHeaderString("content-type", "application/json")
+	res.ReplaceHeaderString("content-type", "application/json")
 	return res
 }
 
 // WithHTML sets HTML content with appropriate content type
 func (res *Response) WithHTML(payload string) *Response {
 	res.Body = []byte(payload)
-	res.SetHeaderString("content-type", "text/html; charset=utf-8")
+	res.ReplaceHeaderString("content-type", "text/html; charset=utf-8")
 	return res
 }
 
 // WithXML sets XML content with appropriate content type
 func (res *Response) WithXML(payload string) *Response {
 	res.Body = []byte(payload)
-	res.SetHeaderString("content-type", "application/xml; charset=utf-8")
+	res.ReplaceHeaderString("content-type", "application/xml; charset=utf-8")
 	return res
 }
 
@@ -127,20 +194,119 @@ func (res *Response) WithXML(payload string) *Response {
 func (res *Response) WithFile(filename string, data []byte, contentType string) *Response {
 	res.Body = data
 	if contentType != "" {
-		res.SetHeaderString("content-type", contentType)
+		res.ReplaceHeaderString("content-type", contentType)
 	}
-	res.SetHeaderString("content-disposition", "attachment; filename=\""+filename+"\"")
+	res.ReplaceHeaderString("content-disposition", "attachment; filename=\""+filename+"\"")
 	return res
 }
 
 // WithRedirect sets up a redirect response
 func (res *Response) WithRedirect(location string, statusCode uint16) *Response {
 	res.Status = statusCode
-	res.SetHeaderString("location", location)
+	res.ReplaceHeaderString("location", location)
 	res.Body = nil
 	return res
 }
 
+// Write appends data to the body, which makes the response an io.Writer for io.Copy and encoders.
+// Once the body outgrows ResponseBufferSize on a connection the headers are sent and the body
+// continues with chunked encoding, headers set afterwards are lost
+func (res *Response) Write(data []byte) (int, error) {
+	if res.ended {
+		return 0, errResponseEnded
+	}
+
+	res.Body = append(res.Body, data...)
+	if err := res.flushBody(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (res *Response) WriteString(data string) (int, error) {
+	if res.ended {
+		return 0, errResponseEnded
+	}
+
+	res.Body = append(res.Body, data...)
+	if err := res.flushBody(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// ReadFrom reads r into the body until EOF without an intermediate buffer, large bodies switch to
+// chunked encoding like Write does
+func (res *Response) ReadFrom(r io.Reader) (int64, error) {
+	if res.ended {
+		return 0, errResponseEnded
+	}
+
+	var total int64
+	for {
+		if cap(res.Body)-len(res.Body) < bytes.MinRead {
+			res.Body = slices.Grow(res.Body, bytes.MinRead)
+		}
+
+		n, err := r.Read(res.Body[len(res.Body):cap(res.Body)])
+		res.Body = res.Body[:len(res.Body)+n]
+		total += int64(n)
+
+		if flushErr := res.flushBody(); flushErr != nil {
+			return total, flushErr
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// flushBody sends a body which outgrew ResponseBufferSize as a chunk, the headers go first
+func (res *Response) flushBody() error {
+	if len(res.Body) < ResponseBufferSize || res.writer == nil {
+		return nil
+	}
+
+	if !res.headerWritten {
+		res.Chunked = true
+		if err := res.writeHeaders(res.writer); err != nil {
+			return err
+		}
+	}
+	if err := res.writeChunk(res.writer, res.Body); err != nil {
+		return err
+	}
+	res.streamed += len(res.Body)
+	res.Body = res.Body[:0]
+	return nil
+}
+
+// SetTrailer sets a trailer sent after the last chunk, a response with trailers is always chunked.
+// Trailers set before the headers are sent are announced in the Trailer header, for streamed
+// bodies set the Trailer header up front
+func (res *Response) SetTrailer(name, value string) {
+	for i := range res.trailers {
+		if strings.EqualFold(res.trailers[i].name, name) {
+			res.trailers[i].value = value
+			return
+		}
+	}
+	res.trailers = append(res.trailers, trailer{name: name, value: value})
+}
+
+// Trailer returns the value of the trailer matching name (case-insensitive)
+func (res *Response) Trailer(name string) (string, bool) {
+	for _, t := range res.trailers {
+		if strings.EqualFold(t.name, name) {
+			return t.value, true
+		}
+	}
+	return "", false
+}
+
 // WriteChunk writes a chunk for chunked transfer encoding
 func (res *Response) WriteChunk(data []byte) error {
 	if res.writer == nil {
@@ -155,8 +321,7 @@ func (res *Response) WriteChunk(data []byte) error {
 	chunkSize := len(data)
 	if chunkSize == 0 {
 		// End chunk
-		_, err := res.writer.WriteString("0\r\n\r\n")
-		return err
+		return res.writeChunkEnd(res.writer)
 	}
 
 	// Convert size to hex and write
@@ -170,6 +335,7 @@ func (res *Response) WriteChunk(data []byte) error {
 	if _, err := res.writer.Write(data); err != nil {
 		return err
 	}
+	res.streamed += chunkSize
 	if _, err := res.writer.WriteString("\r\n"); err != nil {
 		return err
 	}
@@ -177,13 +343,42 @@ func (res *Response) WriteChunk(data []byte) error {
 	return res.writer.Flush()
 }
 
+// SetWriter associates the response with the writer used by WriteChunk and StartStreaming.
+// The server sets the connection writer for every request, tests can record streamed output
+func (res *Response) SetWriter(bw *bufio.Writer) {
+	res.writer = bw
+}
+
 // StartChunkedResponse initializes chunked transfer encoding
 func (res *Response) StartChunkedResponse() {
 	res.Chunked = true
 	res.SetHeaderString("transfer-encoding", "chunked")
 }
 
+// WriteTo writes the response, or finishes a body started with Write or the streaming helpers.
+// Nothing is written once the last chunk was sent
 func (res *Response) WriteTo(bw *bufio.Writer) error {
+	if res.ended {
+		return nil
+	}
+
+	if res.headerWritten {
+		if err := res.writeChunk(bw, res.Body); err != nil {
+			return err
+		}
+		res.streamed += len(res.Body)
+		res.Body = nil
+		if err := res.writeChunkEnd(bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+
+	// Trailers follow the last chunk
+	if len(res.trailers) > 0 {
+		res.Chunked = true
+	}
+
 	// Fast path for empty body responses (no chunking needed)
 	if len(res.Body) == 0 && res.headerCount == 0 && res.Status == StatusOK && !res.Chunked {
 		if res.KeepAlive {
@@ -195,56 +390,11 @@ func (res *Response) WriteTo(bw *bufio.Writer) error {
 				return err
 			}
 		}
-		return nil
+		return bw.Flush()
 	}
 
-	// Build headers
-	var n int
-
-	// Write status line
-	if res.Status == StatusOK {
-		n += copy(res.headerBuf[n:], http200OK)
-	} else {
-		n += copy(res.headerBuf[n:], "HTTP/1.1 ")
-		n += writeIntToBuffer(int(res.Status), res.headerBuf[n:])
-		if message := statusMessages[res.Status]; message != "" {
-			n += copy(res.headerBuf[n:], " "+message)
-		} else {
-			n += copy(res.headerBuf[n:], " Unknown")
-		}
-		n += copy(res.headerBuf[n:], "\r\n")
-	}
-
-	// Write Connection header
-	if res.KeepAlive {
-		n += copy(res.headerBuf[n:], connectionKeepAlive)
-	} else {
-		n += copy(res.headerBuf[n:], connectionClose)
-	}
-
-	// Write Transfer-Encoding or Content-Length
-	if res.Chunked {
-		n += copy(res.headerBuf[n:], headerTransferEncodingChunked)
-	} else {
-		n += copy(res.headerBuf[n:], contentLengthPrefix)
-		n += writeIntToBuffer(len(res.Body), res.headerBuf[n:])
-		n += copy(res.headerBuf[n:], "\r\n")
-	}
-
-	// Write custom headers
-	for i := 0; i < res.headerCount; i++ {
-		h := &res.headers[i]
-		n += copy(res.headerBuf[n:], h.Name[:h.NameLen])
-		n += copy(res.headerBuf[n:], ": ")
-		n += copy(res.headerBuf[n:], h.Value[:h.ValueLen])
-		n += copy(res.headerBuf[n:], "\r\n")
-	}
-
-	// End headers
-	n += copy(res.headerBuf[n:], "\r\n")
-
 	// Write all headers at once
-	if _, err := bw.Write(res.headerBuf[:n]); err != nil {
+	if _, err := bw.Write(res.appendHeaders(res.headerBuf[:0])); err != nil {
 		return err
 	}
 
@@ -270,44 +420,70 @@ func (res *Response) WriteTo(bw *bufio.Writer) error {
 }
 
 func (res *Response) writeHeaders(bw *bufio.Writer) error {
-	var n int
+	if _, err := bw.Write(res.appendHeaders(res.headerBuf[:0])); err != nil {
+		return err
+	}
+	res.headerWritten = true
 
+	return nil
+}
+
+// appendHeaders appends the status line and headers, buf grows beyond headerBuf when needed
+func (res *Response) appendHeaders(buf []byte) []byte {
 	// Write status line
 	if res.Status == StatusOK {
-		n += copy(res.headerBuf[n:], http200OK)
+		buf = append(buf, http200OK...)
 	} else {
-		n += copy(res.headerBuf[n:], "HTTP/1.1 ")
-		n += writeIntToBuffer(int(res.Status), res.headerBuf[n:])
-		n += copy(res.headerBuf[n:], " OK\r\n") // Simplified
+		buf = append(buf, "HTTP/1.1 "...)
+		buf = strconv.AppendUint(buf, uint64(res.Status), 10)
+		if message := StatusText(res.Status); message != "" {
+			buf = append(buf, ' ')
+			buf = append(buf, message...)
+		} else {
+			buf = append(buf, " Unknown"...)
+		}
+		buf = append(buf, "\r\n"...)
 	}
 
 	// Write Connection header
 	if res.KeepAlive {
-		n += copy(res.headerBuf[n:], connectionKeepAlive)
+		buf = append(buf, connectionKeepAlive...)
 	} else {
-		n += copy(res.headerBuf[n:], connectionClose)
+		buf = append(buf, connectionClose...)
 	}
 
-	// Write Transfer-Encoding
-	n += copy(res.headerBuf[n:], headerTransferEncodingChunked)
+	// Write Transfer-Encoding or Content-Length
+	if res.Chunked {
+		buf = append(buf, headerTransferEncodingChunked...)
+	} else {
+		buf = append(buf, contentLengthPrefix...)
+		buf = strconv.AppendInt(buf, int64(len(res.Body)), 10)
+		buf = append(buf, "\r\n"...)
+	}
 
 	// Write custom headers
 	for i := 0; i < res.headerCount; i++ {
-		h := &res.headers[i]
-		n += copy(res.headerBuf[n:], h.Name[:h.NameLen])
-		n += copy(res.headerBuf[n:], ": ")
-		n += copy(res.headerBuf[n:], h.Value[:h.ValueLen])
-		n += copy(res.headerBuf[n:], "\r\n")
+		h := res.header(i)
+		buf = append(buf, h.Name[:h.NameLen]...)
+		buf = append(buf, ": "...)
+		buf = append(buf, res.headerValue(i)...)
+		buf = append(buf, "\r\n"...)
+	}
+
+	// Announce the trailers known by now
+	if _, found := res.Header(headerTrailer); len(res.trailers) > 0 && !found {
+		buf = append(buf, "trailer: "...)
+		for i, t := range res.trailers {
+			if i > 0 {
+				buf = append(buf, ", "...)
+			}
+			buf = append(buf, t.name...)
+		}
+		buf = append(buf, "\r\n"...)
 	}
 
 	// End headers
-	n += copy(res.headerBuf[n:], "\r\n")
-
-	if _, err := bw.Write(res.headerBuf[:n]); err != nil {
-		return err
-	}
-
-	return nil
+	return append(buf, "\r\n"...)
 }
 
 func (res *Response) writeChunk(bw *bufio.Writer, data []byte) error {
@@ -336,11 +512,24 @@ func (res *Response) writeChunk(bw *bufio.Writer, data []byte) error {
 }
 
 func (res *Response) writeChunkEnd(bw *bufio.Writer) error {
-	if _, err := bw.Write(chunkEndBytes); err != nil {
+	res.ended = true
+
+	if len(res.trailers) == 0 {
+		_, err := bw.Write(chunkEndBytes)
 		return err
 	}
 
-	return nil
+	buf := append(res.headerBuf[:0], "0\r\n"...)
+	for _, t := range res.trailers {
+		buf = append(buf, t.name...)
+		buf = append(buf, ": "...)
+		buf = append(buf, t.value...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "\r\n"...)
+
+	_, err := bw.Write(buf)
+	return err
 }
 
 func (res *Response) StartChunked(bw *bufio.Writer) (*ChunkWriter, error) {
@@ -362,7 +551,11 @@ type ChunkWriter struct {
 }
 
 func (cw *ChunkWriter) WriteChunk(data []byte) error {
-	return cw.res.writeChunk(cw.bw, data)
+	if err := cw.res.writeChunk(cw.bw, data); err != nil {
+		return err
+	}
+	cw.res.streamed += len(data)
+	return nil
 }
 
 func (cw *ChunkWriter) Close() error {
@@ -442,19 +635,58 @@ func (r *Response) AddCookie(cookie Cookie) {
 
 // Helper method to add headers without overwriting (for Set-Cookie)
 func (r *Response) addHeader(name, value []byte) {
-	if r.headerCount >= len(r.headers) {
-		return // Skip if we've reached max headers
+	i := r.headerCount
+	if extra := i - len(r.headers); extra >= len(r.extraHeaders) {
+		r.extraHeaders = append(r.extraHeaders, Header{})
 	}
 
-	h := &r.headers[r.headerCount]
+	h := r.header(i)
 
 	// Copy name (truncate if too long)
-	h.NameLen = min(len(name), len(h.Name))
-	copy(h.Name[:h.NameLen], name[:h.NameLen])
-
-	// Copy value (truncate if too long)
-	h.ValueLen = min(len(value), len(h.Value))
-	copy(h.Value[:h.ValueLen], value[:h.ValueLen])
+	h.NameLen = copy(h.Name[:], name)
 
+	r.setHeaderValue(i, value)
 	r.headerCount++
 }
+
+// hasHeaderBefore reports whether one of the first n headers is named name
+func (r *Response) hasHeaderBefore(n int, name []byte) bool {
+	for i := 0; i < n; i++ {
+		h := r.header(i)
+		if bytes.EqualFold(h.Name[:h.NameLen], name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Response) header(i int) *Header {
+	if i < len(r.headers) {
+		return &r.headers[i]
+	}
+	return &r.extraHeaders[i-len(r.headers)]
+}
+
+// setHeaderValue stores value inline, or in the overflow buffer when it does not fit
+func (r *Response) setHeaderValue(i int, value []byte) {
+	h := r.header(i)
+	h.ValueLen = len(value)
+
+	if len(value) <= len(h.Value) {
+		copy(h.Value[:], value)
+		return
+	}
+
+	for len(r.longHeaderValues) <= i {
+		r.longHeaderValues = append(r.longHeaderValues, nil)
+	}
+	r.longHeaderValues[i] = append(r.longHeaderValues[i][:0], value...)
+}
+
+func (r *Response) headerValue(i int) []byte {
+	h := r.header(i)
+	if h.ValueLen > len(h.Value) {
+		return r.longHeaderValues[i][:h.ValueLen]
+	}
+	return h.Value[:h.ValueLen]
+}
@@ -3,6 +3,10 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -91,6 +95,181 @@ func TestResponseWrite_EmptyBody(t *testing.T) {
 		t.Errorf("expected empty body, got %q", got)
 	}
 }
+func TestResponseSetHeaderAppends(t *testing.T) {
+	var res Response
+	res.SetHeaderString("Link", "</a.css>; rel=preload")
+	res.SetHeaderString("link", "</b.js>; rel=preload")
+
+	if res.headerCount != 2 {
+		t.Errorf("Expected 2 headers, got %d", res.headerCount)
+	}
+	if value, _ := res.Header([]byte("Link")); string(value) != "</a.css>; rel=preload" {
+		t.Errorf("Expected the first value, got %q", value)
+	}
+}
+
+func TestResponseReplaceHeader(t *testing.T) {
+	var res Response
+	res.SetHeaderString("Content-Type", "text/plain")
+	res.ReplaceHeaderString("content-type", "application/json")
+
+	if res.headerCount != 1 {
+		t.Errorf("Expected 1 header, got %d", res.headerCount)
+	}
+	if value, _ := res.Header([]byte("Content-Type")); string(value) != "application/json" {
+		t.Errorf("Expected replaced value, got %q", value)
+	}
+
+	// The With helpers set a single Content-Type
+	res.WithText("plain")
+	if res.headerCount != 1 {
+		t.Errorf("Expected WithText to replace Content-Type, got %d headers", res.headerCount)
+	}
+	res.ReplaceHeaderString("Content-Type", "application/json")
+
+	res.SetCookieValue("a", "1")
+	res.SetCookieValue("b", "2")
+	if res.headerCount != 3 {
+		t.Errorf("Expected a Set-Cookie header per cookie, got %d headers", res.headerCount)
+	}
+
+	res.DelHeader([]byte("set-cookie"))
+	if _, found := res.Header([]byte("Set-Cookie")); found {
+		t.Error("Expected Set-Cookie headers to be deleted")
+	}
+	if value, _ := res.Header([]byte("Content-Type")); string(value) != "application/json" {
+		t.Errorf("Expected Content-Type to survive deletion, got %q", value)
+	}
+}
+
+func TestResponseWrite_LargeHeaders(t *testing.T) {
+	var res Response
+	res.Reset()
+
+	long := strings.Repeat("a", 2000)
+	res.SetHeaderString("Content-Security-Policy", long)
+	for i := range 20 {
+		res.SetHeaderString("X-Header-"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+
+	buf := &bytes.Buffer{}
+	bw := bufio.NewWriter(buf)
+	if err := res.WriteTo(bw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := buf.String()
+	if !strings.Contains(got, "Content-Security-Policy: "+long+"\r\n") {
+		t.Error("Expected long header value to be written in full")
+	}
+	if !strings.Contains(got, "X-Header-19: 19\r\n") {
+		t.Errorf("Expected headers beyond the inline array, got %q", got)
+	}
+
+	// Reused response must not leak old values
+	res.Reset()
+	res.SetHeaderString("Content-Security-Policy", "default-src 'self'")
+	if value, _ := res.Header([]byte("Content-Security-Policy")); string(value) != "default-src 'self'" {
+		t.Errorf("Expected short value after reset, got %q", value)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	bw := bufio.NewWriter(buf)
+
+	var res Response
+	res.Reset()
+	res.SetWriter(bw)
+
+	// Small bodies stay buffered and get a content-length
+	if _, err := io.WriteString(&res, "hello "); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _ = res.Write([]byte("world"))
+	if res.Chunked || bw.Buffered() > 0 {
+		t.Errorf("Expected a buffered body")
+	}
+	if err := res.WriteTo(bw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "content-length: 11\r\n") || !strings.HasSuffix(got, "hello world") {
+		t.Errorf("Expected a content-length response, got %q", got)
+	}
+
+	// Large bodies continue chunked
+	buf.Reset()
+	res.Reset()
+	res.SetWriter(bw)
+	res.SetHeaderString("Trailer", "X-Checksum")
+
+	body := strings.Repeat("gravel ", ResponseBufferSize/3)
+	n, err := io.Copy(&res, strings.NewReader(body))
+	if err != nil || n != int64(len(body)) {
+		t.Fatalf("Expected %d bytes copied, got %d %v", len(body), n, err)
+	}
+	if !res.Chunked || res.BytesWritten() != len(body) {
+		t.Errorf("Expected a chunked response of %d bytes, got %v %d", len(body), res.Chunked, res.BytesWritten())
+	}
+	res.SetHeaderString("X-Late", "lost")
+	res.SetTrailer("X-Checksum", "abc")
+	if err := res.WriteTo(bw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := res.Write([]byte("more")); err == nil {
+		t.Errorf("Expected error writing after the response ended")
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(buf), nil)
+	if err != nil {
+		t.Fatalf("Reading response failed: %v", err)
+	}
+	got, err := io.ReadAll(resp.Body)
+	if err != nil || string(got) != body {
+		t.Errorf("Expected the copied body, got %d bytes %v", len(got), err)
+	}
+	if resp.Header.Get("X-Late") != "" {
+		t.Errorf("Expected headers set after the switch to be lost")
+	}
+	if resp.Trailer.Get("X-Checksum") != "abc" {
+		t.Errorf("Expected trailer abc, got %v", resp.Trailer)
+	}
+}
+
+func TestResponseReadFromWithoutWriter(t *testing.T) {
+	var res Response
+	res.Reset()
+
+	// Without a connection the whole body is buffered
+	body := strings.Repeat("x", 3*ResponseBufferSize)
+	if n, err := res.ReadFrom(strings.NewReader(body)); err != nil || n != int64(len(body)) {
+		t.Fatalf("Expected %d bytes read, got %d %v", len(body), n, err)
+	}
+	if res.Chunked || string(res.Body) != body {
+		t.Errorf("Expected a buffered body of %d bytes, got %d", len(body), len(res.Body))
+	}
+}
+
+func TestResponseTrailers(t *testing.T) {
+	var res Response
+	res.Reset()
+	res.Body = []byte("data")
+	res.SetTrailer("X-Checksum", "1")
+	res.SetTrailer("x-checksum", "2")
+
+	buf := &bytes.Buffer{}
+	bw := bufio.NewWriter(buf)
+	if err := res.WriteTo(bw); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "HTTP/1.1 200 OK\r\nconnection: keep-alive\r\ntransfer-encoding: chunked\r\ntrailer: X-Checksum\r\n\r\n" +
+		"4\r\ndata\r\n0\r\nX-Checksum: 2\r\n\r\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
 func BenchmarkResponseWrite(b *testing.B) {
 	var res Response
 	res.Status = 200
@@ -4,6 +4,52 @@ type Route struct {
 	Methods []string
 	Path    string
 	Handler Handler
+
+	// Optional unique name, see Router.URL
+	Name string
+
+	// Optional documentation, e.g. for generating an OpenAPI document
+	Doc *RouteDoc
+
+	// Compiled {name:constraint} segments of Path
+	constraints map[string]paramConstraint
+}
+
+// RouteDoc describes a route for API documentation
+type RouteDoc struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+
+	// Value of the type the handler binds, see Bind. Fields tagged path, query or header become
+	// parameters, form fields a form body and json fields a JSON body
+	Request any
+
+	// Values of the response body types by status, nil for responses without a body
+	Responses map[uint16]any
+
+	// Names of the security schemes accepted by the route, e.g. "bearer"
+	Security []string
+}
+
+// RouteRef refers to a registered route, so it can be described after registration
+type RouteRef struct {
+	router *Router
+	index  int
+}
+
+// Name names the route, so its URL can be built with Router.URL
+func (ref RouteRef) Name(name string) RouteRef {
+	ref.router.Routes[ref.index].Name = name
+	return ref
+}
+
+// Doc attaches documentation to the route
+func (ref RouteRef) Doc(doc RouteDoc) RouteRef {
+	ref.router.Routes[ref.index].Doc = &doc
+	return ref
 }
 
 var NotFoundHandler Handler = func(req *Request, res *Response) {
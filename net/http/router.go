@@ -1,9 +1,15 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/freekieb7/gravel/uuid"
 )
 
 type Router struct {
@@ -13,6 +19,13 @@ type Router struct {
 	// Add route tree for better performance
 	staticRoutes map[string]map[string]Handler // path -> method -> handler
 	hasWildcards bool
+	// Route tables of Host in registration order
+	hosts []hostRoutes
+}
+
+type hostRoutes struct {
+	pattern string
+	router  *Router
 }
 
 func NewRouter() Router {
@@ -23,66 +36,74 @@ func NewRouter() Router {
 	}
 }
 
-func (router *Router) GET(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodGet}, path, handler, middleware...)
+func (router *Router) GET(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodGet}, path, handler, middleware...)
 }
 
-func (router *Router) HEAD(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodHead}, path, handler, middleware...)
+func (router *Router) HEAD(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodHead}, path, handler, middleware...)
 }
 
-func (router *Router) POST(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodPost}, path, handler, middleware...)
+func (router *Router) POST(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodPost}, path, handler, middleware...)
 }
 
-func (router *Router) PUT(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodPut}, path, handler, middleware...)
+func (router *Router) PUT(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodPut}, path, handler, middleware...)
 }
 
-func (router *Router) Patch(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodPatch}, path, handler, middleware...)
+func (router *Router) Patch(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodPatch}, path, handler, middleware...)
 }
 
-func (router *Router) DELETE(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodDelete}, path, handler, middleware...)
+func (router *Router) DELETE(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodDelete}, path, handler, middleware...)
 }
 
-func (router *Router) CONNECT(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodConnect}, path, handler, middleware...)
+func (router *Router) CONNECT(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodConnect}, path, handler, middleware...)
 }
 
-func (router *Router) OPTIONS(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodOptions}, path, handler, middleware...)
+func (router *Router) OPTIONS(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodOptions}, path, handler, middleware...)
 }
 
-func (router *Router) TRACE(path string, handler Handler, middleware ...Middleware) {
-	router.Any([]string{http.MethodTrace}, path, handler, middleware...)
+func (router *Router) TRACE(path string, handler Handler, middleware ...Middleware) RouteRef {
+	return router.Any([]string{http.MethodTrace}, path, handler, middleware...)
 }
 
-func (router *Router) Any(methods []string, path string, handler Handler, middleware ...Middleware) {
+func (router *Router) Any(methods []string, path string, handler Handler, middleware ...Middleware) RouteRef {
 	// Apply middleware in reverse order
 	for i := len(middleware) - 1; i >= 0; i-- {
 		handler = middleware[i](handler)
 	}
 
+	return router.addRoute(Route{
+		Methods: methods,
+		Path:    path,
+		Handler: handler,
+	})
+}
+
+func (router *Router) addRoute(route Route) RouteRef {
+	route.constraints = routeConstraints(route.Path)
+
 	// Check if path has wildcards
-	if strings.Contains(path, ":") || strings.Contains(path, "*") {
+	if strings.ContainsAny(route.Path, ":*{") {
 		router.hasWildcards = true
 	} else {
 		// Add to static route map for O(1) lookup
-		if router.staticRoutes[path] == nil {
-			router.staticRoutes[path] = make(map[string]Handler)
+		if router.staticRoutes[route.Path] == nil {
+			router.staticRoutes[route.Path] = make(map[string]Handler)
 		}
-		for _, method := range methods {
-			router.staticRoutes[path][method] = handler
+		for _, method := range route.Methods {
+			router.staticRoutes[route.Path][method] = route.Handler
 		}
 	}
 
-	router.Routes = append(router.Routes, Route{
-		Methods: methods,
-		Path:    path,
-		Handler: handler,
-	})
+	router.Routes = append(router.Routes, route)
+
+	return RouteRef{router: router, index: len(router.Routes) - 1}
 }
 
 func (router *Router) Group(path string, groupFunc func(group *Router), middlewareList ...Middleware) {
@@ -96,11 +117,158 @@ func (router *Router) Group(path string, groupFunc func(group *Router), middlewa
 			route.Handler = middleware(route.Handler)
 		}
 
-		router.Routes = append(router.Routes, route)
+		router.addRoute(route)
 	}
 }
 
+// URL builds the path of the route registered with name, e.g. for a redirect
+//
+//	location, err := router.URL("user", "id", "42", "tab", "settings") // /users/42?tab=settings
+//	res.WithRedirect(location, http.StatusSeeOther)
+//
+// params are name value pairs, values are escaped and params the path does not use become the
+// query string. Routes of Host tables are included, the host is not part of the URL
+func (router *Router) URL(name string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("router: odd number of params for route %q", name)
+	}
+
+	route, found := router.namedRoute(name)
+	if !found {
+		return "", fmt.Errorf("router: no route named %q", name)
+	}
+
+	used := make([]bool, len(params)/2)
+	param := func(paramName string) (string, bool) {
+		for i := 0; i < len(params); i += 2 {
+			if params[i] == paramName {
+				used[i/2] = true
+				return params[i+1], true
+			}
+		}
+		return "", false
+	}
+
+	segments := strings.Split(route.Path, "/")
+	for i, segment := range segments {
+		if segment == "*" && i == len(segments)-1 {
+			value, _ := param("*")
+			// The wildcard captures a path, keep its slashes
+			rest := strings.Split(value, "/")
+			for j := range rest {
+				rest[j] = url.PathEscape(rest[j])
+			}
+			segments[i] = strings.Join(rest, "/")
+			continue
+		}
+
+		paramName, _, isParam := routeParam(segment)
+		if !isParam {
+			continue
+		}
+		value, found := param(paramName)
+		if !found || value == "" {
+			return "", fmt.Errorf("router: route %q requires param %q", name, paramName)
+		}
+		if check := route.constraints[paramName]; check != nil && !check(value) {
+			return "", fmt.Errorf("router: param %q of route %q does not match %s", paramName, name, segment)
+		}
+		segments[i] = url.PathEscape(value)
+	}
+
+	var query []string
+	for i := 0; i < len(params); i += 2 {
+		if !used[i/2] {
+			query = append(query, url.QueryEscape(params[i])+"="+url.QueryEscape(params[i+1]))
+		}
+	}
+
+	path := strings.Join(segments, "/")
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+	return path, nil
+}
+
+func (router *Router) namedRoute(name string) (Route, bool) {
+	for _, route := range router.Routes {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	for _, host := range router.hosts {
+		if route, found := host.router.namedRoute(name); found {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// Host registers routes only served for requests to host, e.g. "api.example.com", "*.example.com"
+// matching any single label or "{tenant}.example.com" capturing a label like a path parameter.
+// Exact hosts win over patterns, patterns are tried in registration order. Requests to other hosts
+// and, unless the host router sets a NotFoundHandler, unmatched requests use the routes of router
+func (router *Router) Host(pattern string, hostFunc func(host *Router)) {
+	pattern = strings.ToLower(pattern)
+	for _, host := range router.hosts {
+		if host.pattern == pattern {
+			hostFunc(host.router)
+			return
+		}
+	}
+
+	host := NewRouter()
+	host.NotFoundHandler = nil
+	hostFunc(&host)
+
+	router.hosts = append(router.hosts, hostRoutes{pattern: pattern, router: &host})
+}
+
 func (router *Router) Handler() Handler {
+	handler := router.routesHandler()
+	if len(router.hosts) == 0 {
+		return handler
+	}
+
+	exactHosts := make(map[string]Handler)
+	var patternHosts []hostRoutes
+	for _, host := range router.hosts {
+		hostRouter := *host.router
+		if hostRouter.NotFoundHandler == nil {
+			hostRouter.NotFoundHandler = handler
+		}
+
+		if strings.ContainsAny(host.pattern, "*{") {
+			patternHosts = append(patternHosts, hostRoutes{pattern: host.pattern, router: &hostRouter})
+		} else {
+			exactHosts[host.pattern] = hostRouter.Handler()
+		}
+	}
+
+	patternHandlers := make([]Handler, len(patternHosts))
+	for i, host := range patternHosts {
+		patternHandlers[i] = host.router.Handler()
+	}
+
+	return func(req *Request, res *Response) {
+		host := req.Host()
+
+		if hostHandler, exists := exactHosts[host]; exists {
+			hostHandler(req, res)
+			return
+		}
+		for i, patternHost := range patternHosts {
+			if matchHost(patternHost.pattern, host, req) {
+				patternHandlers[i](req, res)
+				return
+			}
+		}
+
+		handler(req, res)
+	}
+}
+
+func (router *Router) routesHandler() Handler {
 	return func(req *Request, res *Response) {
 		path := string(req.Path)
 		method := string(req.Method)
@@ -108,6 +276,7 @@ func (router *Router) Handler() Handler {
 		// Fast path: check static routes first (O(1) lookup)
 		if methodMap, exists := router.staticRoutes[path]; exists {
 			if handler, exists := methodMap[method]; exists {
+				req.route = path
 				handler(req, res)
 				return
 			}
@@ -116,11 +285,13 @@ func (router *Router) Handler() Handler {
 		// Slower path: check wildcard routes if any exist
 		if router.hasWildcards {
 			for _, route := range router.Routes {
-				if route.Path == path {
-					if slices.Contains(route.Methods, method) {
-						route.Handler(req, res)
-						return
-					}
+				if !slices.Contains(route.Methods, method) {
+					continue
+				}
+				if matchRoutePath(route.Path, route.constraints, path, req) {
+					req.route = route.Path
+					route.Handler(req, res)
+					return
 				}
 			}
 		}
@@ -129,3 +300,134 @@ func (router *Router) Handler() Handler {
 		router.NotFoundHandler(req, res)
 	}
 }
+
+type pathParam struct {
+	name  string
+	value string
+}
+
+// matchRoutePath matches path segment by segment, {name} and :name capture a segment and a
+// final * captures the rest. Captured values are only kept on a match
+func matchRoutePath(pattern string, constraints map[string]paramConstraint, path string, req *Request) bool {
+	captured := len(req.params)
+
+	for {
+		patternSegment, patternRest, patternMore := strings.Cut(pattern, "/")
+		if patternSegment == "*" && !patternMore {
+			req.params = append(req.params, pathParam{name: "*", value: path})
+			return true
+		}
+
+		pathSegment, pathRest, pathMore := strings.Cut(path, "/")
+		if name, _, isParam := routeParam(patternSegment); isParam {
+			if pathSegment == "" {
+				break
+			}
+			if value, err := url.PathUnescape(pathSegment); err == nil {
+				pathSegment = value
+			}
+			if check := constraints[name]; check != nil && !check(pathSegment) {
+				break
+			}
+			req.params = append(req.params, pathParam{name: name, value: pathSegment})
+		} else if patternSegment != pathSegment {
+			break
+		}
+
+		if patternMore != pathMore {
+			break
+		}
+		if !patternMore {
+			return true
+		}
+		pattern, path = patternRest, pathRest
+	}
+
+	req.params = req.params[:captured]
+	return false
+}
+
+// matchHost matches host label by label, * matches any label and {name} captures it
+func matchHost(pattern, host string, req *Request) bool {
+	captured := len(req.params)
+
+	for {
+		patternLabel, patternRest, patternMore := strings.Cut(pattern, ".")
+		hostLabel, hostRest, hostMore := strings.Cut(host, ".")
+		if hostLabel == "" {
+			break
+		}
+
+		if strings.HasPrefix(patternLabel, "{") && strings.HasSuffix(patternLabel, "}") {
+			req.params = append(req.params, pathParam{name: patternLabel[1 : len(patternLabel)-1], value: hostLabel})
+		} else if patternLabel != "*" && patternLabel != hostLabel {
+			break
+		}
+
+		if patternMore != hostMore {
+			break
+		}
+		if !patternMore {
+			return true
+		}
+		pattern, host = patternRest, hostRest
+	}
+
+	req.params = req.params[:captured]
+	return false
+}
+
+// routeParam parses a :name, {name} or {name:constraint} segment
+func routeParam(segment string) (string, string, bool) {
+	if strings.HasPrefix(segment, ":") {
+		return segment[1:], "", true
+	}
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		name, constraint, _ := strings.Cut(segment[1:len(segment)-1], ":")
+		return name, constraint, true
+	}
+	return "", "", false
+}
+
+// paramConstraint reports whether a captured value satisfies the constraint of its segment
+type paramConstraint func(value string) bool
+
+var paramConstraints = map[string]paramConstraint{
+	"int": func(value string) bool {
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	},
+	"uuid": func(value string) bool {
+		_, err := uuid.Parse(value)
+		return err == nil
+	},
+}
+
+// routeConstraints compiles the constraints of the {name:constraint} segments of path. A constraint
+// is int, uuid or a regular expression the whole value must match, e.g. {slug:[a-z-]+}
+func routeConstraints(path string) map[string]paramConstraint {
+	var constraints map[string]paramConstraint
+
+	for segment := range strings.SplitSeq(path, "/") {
+		name, constraint, isParam := routeParam(segment)
+		if !isParam || constraint == "" {
+			continue
+		}
+
+		check, found := paramConstraints[constraint]
+		if !found {
+			re, err := regexp.Compile("^(?:" + constraint + ")$")
+			if err != nil {
+				panic(fmt.Sprintf("router: invalid constraint of param %q in %s: %v", name, path, err))
+			}
+			check = re.MatchString
+		}
+
+		if constraints == nil {
+			constraints = make(map[string]paramConstraint)
+		}
+		constraints[name] = check
+	}
+
+	return constraints
+}
@@ -0,0 +1,261 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/freekieb7/gravel/uuid"
+)
+
+func TestRouterPathParams(t *testing.T) {
+	router := NewRouter()
+
+	var captured map[string]string
+	capture := func(names ...string) Handler {
+		return func(req *Request, res *Response) {
+			captured = make(map[string]string)
+			for _, name := range names {
+				captured[name], _ = req.Param(name)
+			}
+		}
+	}
+	router.GET("/users/{id}", capture("id"))
+	router.GET("/orgs/:org/repos/{repo}", capture("org", "repo"))
+	router.GET("/files/*", capture("*"))
+	handler := router.Handler()
+
+	tests := []struct {
+		path     string
+		route    string
+		expected map[string]string
+	}{
+		{"/users/42", "/users/{id}", map[string]string{"id": "42"}},
+		{"/orgs/gravel/repos/web%20site", "/orgs/:org/repos/{repo}", map[string]string{"org": "gravel", "repo": "web site"}},
+		{"/files/css/site.css", "/files/*", map[string]string{"*": "css/site.css"}},
+		{"/users/42/extra", "", nil},
+		{"/users/", "", nil},
+	}
+
+	for _, tt := range tests {
+		captured = nil
+		req := parseTestRequest(t, "GET "+tt.path+" HTTP/1.1\r\n\r\n")
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if req.Route() != tt.route {
+			t.Errorf("Expected %s to match %q, got %q", tt.path, tt.route, req.Route())
+		}
+		for name, value := range tt.expected {
+			if captured[name] != value {
+				t.Errorf("Expected param %s=%q for %s, got %q", name, value, tt.path, captured[name])
+			}
+		}
+		if tt.expected == nil && res.Status != StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", tt.path, res.Status)
+		}
+	}
+}
+
+func TestRouterHosts(t *testing.T) {
+	router := NewRouter()
+
+	served := func(name string) Handler {
+		return func(req *Request, res *Response) {
+			tenant, _ := req.Param("tenant")
+			res.WithText(name + tenant)
+		}
+	}
+	router.GET("/", served("default"))
+	router.GET("/health", served("health"))
+	router.Host("api.example.com", func(host *Router) {
+		host.GET("/", served("api"))
+	})
+	router.Host("admin.example.com", func(host *Router) {
+		host.GET("/", served("admin"))
+		host.NotFoundHandler = NotFoundHandler
+	})
+	router.Host("{tenant}.example.com", func(host *Router) {
+		host.GET("/", served("tenant "))
+	})
+	router.Host("*.example.org", func(host *Router) {
+		host.GET("/", served("org"))
+	})
+	handler := router.Handler()
+
+	tests := []struct {
+		request  string
+		status   uint16
+		expected string
+	}{
+		{"GET / HTTP/1.1\r\nHost: api.example.com\r\n\r\n", StatusOK, "api"},
+		{"GET / HTTP/1.1\r\nHost: API.Example.com:8080\r\n\r\n", StatusOK, "api"},
+		{"GET / HTTP/1.1\r\nHost: acme.example.com\r\n\r\n", StatusOK, "tenant acme"},
+		{"GET / HTTP/1.1\r\nHost: www.example.org\r\n\r\n", StatusOK, "org"},
+		{"GET / HTTP/1.1\r\nHost: a.b.example.org\r\n\r\n", StatusOK, "default"},
+		{"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n", StatusOK, "default"},
+		{"GET / HTTP/1.1\r\n\r\n", StatusOK, "default"},
+		// Unmatched host routes fall back to the default routes unless the host sets a NotFoundHandler
+		{"GET /health HTTP/1.1\r\nHost: acme.example.com\r\n\r\n", StatusOK, "healthacme"},
+		{"GET /health HTTP/1.1\r\nHost: admin.example.com\r\n\r\n", StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		req := parseTestRequest(t, tt.request)
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if res.Status != tt.status || string(res.Body) != tt.expected {
+			t.Errorf("Expected %d %q for %q, got %d %q", tt.status, tt.expected, tt.request, res.Status, res.Body)
+		}
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	router := NewRouter()
+	router.GET("/", nil).Name("home")
+	router.Group("/v1", func(group *Router) {
+		group.GET("/users/{id}", nil).Name("user")
+		group.GET("/orgs/:org/files/*", nil).Name("files")
+	})
+	router.Host("admin.example.com", func(host *Router) {
+		host.GET("/dashboard", nil).Name("dashboard")
+	})
+
+	tests := []struct {
+		name     string
+		params   []string
+		expected string
+	}{
+		{"home", nil, "/"},
+		{"user", []string{"id", "42"}, "/v1/users/42"},
+		{"user", []string{"id", "a/b c", "tab", "settings & more"}, "/v1/users/a%2Fb%20c?tab=settings+%26+more"},
+		{"files", []string{"org", "gravel", "*", "css/site main.css"}, "/v1/orgs/gravel/files/css/site%20main.css"},
+		{"dashboard", nil, "/dashboard"},
+	}
+	for _, tt := range tests {
+		got, err := router.URL(tt.name, tt.params...)
+		if err != nil || got != tt.expected {
+			t.Errorf("Expected %s for %s %v, got %s %v", tt.expected, tt.name, tt.params, got, err)
+		}
+	}
+
+	for _, params := range [][]string{{}, {"id"}, {"id", ""}} {
+		if _, err := router.URL("user", params...); err == nil {
+			t.Errorf("Expected error for params %v", params)
+		}
+	}
+	if _, err := router.URL("unknown"); err == nil {
+		t.Errorf("Expected error for unknown route")
+	}
+}
+
+func TestRouterGroupDispatch(t *testing.T) {
+	router := NewRouter()
+	router.Group("/v1", func(group *Router) {
+		group.GET("/status", func(req *Request, res *Response) { res.WithText("static") })
+		group.GET("/users/{id}", func(req *Request, res *Response) { res.WithText("param") })
+	})
+	handler := router.Handler()
+
+	for path, expected := range map[string]string{"/v1/status": "static", "/v1/users/1": "param"} {
+		req := parseTestRequest(t, "GET "+path+" HTTP/1.1\r\n\r\n")
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if string(res.Body) != expected {
+			t.Errorf("Expected %q for %s, got %d %q", expected, path, res.Status, res.Body)
+		}
+	}
+}
+
+func TestRouterParamConstraints(t *testing.T) {
+	noop := func(req *Request, res *Response) {}
+	router := NewRouter()
+	router.GET("/items/{id:int}", noop)
+	router.GET("/items/{uuid:uuid}", noop)
+	router.GET("/items/{slug:[a-z-]+}", noop)
+	router.GET("/items/{code:[0-9]{3}x}", noop)
+	router.GET("/items/{any}", noop)
+	handler := router.Handler()
+
+	tests := map[string]string{
+		"/items/42": "/items/{id:int}",
+		"/items/-7": "/items/{id:int}",
+		"/items/0f8fad5b-d9cb-469f-a165-70867728950e": "/items/{uuid:uuid}",
+		"/items/hello-world":                          "/items/{slug:[a-z-]+}",
+		"/items/123x":                                 "/items/{code:[0-9]{3}x}",
+		"/items/Hello":                                "/items/{any}",
+		"/items/hello-world2":                         "/items/{any}",
+	}
+	for path, expected := range tests {
+		req := parseTestRequest(t, "GET "+path+" HTTP/1.1\r\n\r\n")
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if req.Route() != expected {
+			t.Errorf("Expected %s to match %s, got %q", path, expected, req.Route())
+		}
+	}
+
+	router.GET("/orders/{id:int}", noop).Name("order")
+	if _, err := router.URL("order", "id", "abc"); err == nil {
+		t.Errorf("Expected error for a param violating its constraint")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for an invalid constraint")
+		}
+	}()
+	router.GET("/broken/{id:[0-9}", noop)
+}
+
+func TestRequestTypedParams(t *testing.T) {
+	router := NewRouter()
+	var id int
+	var orderID uuid.UUID
+	router.GET("/users/{id}", HandleErrors(func(req *Request, res *Response) error {
+		n, err := req.ParamInt("id")
+		if err != nil {
+			return err
+		}
+		id = n
+		return nil
+	}))
+	router.GET("/orders/{id}", HandleErrors(func(req *Request, res *Response) error {
+		parsed, err := req.ParamUUID("id")
+		if err != nil {
+			return err
+		}
+		orderID = parsed
+		return nil
+	}))
+	handler := router.Handler()
+
+	tests := []struct {
+		path   string
+		status uint16
+	}{
+		{"/users/42", StatusOK},
+		{"/users/abc", StatusBadRequest},
+		{"/orders/0f8fad5b-d9cb-469f-a165-70867728950e", StatusOK},
+		{"/orders/42", StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := parseTestRequest(t, "GET "+tt.path+" HTTP/1.1\r\n\r\n")
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if res.Status != tt.status {
+			t.Errorf("Expected %d for %s, got %d", tt.status, tt.path, res.Status)
+		}
+	}
+
+	if id != 42 || orderID.String() != "0f8fad5b-d9cb-469f-a165-70867728950e" {
+		t.Errorf("Expected parsed params, got %d %s", id, orderID)
+	}
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Common Content-Security-Policy sources
+const (
+	CSPSelf          = "'self'"
+	CSPNone          = "'none'"
+	CSPUnsafeInline  = "'unsafe-inline'"
+	CSPStrictDynamic = "'strict-dynamic'"
+
+	// CSPNonce is replaced by 'nonce-<value>' with a fresh nonce for every request, see CSPNonceValue
+	CSPNonce = "'nonce'"
+)
+
+const (
+	cspNonceValueKey = "gravel.csp_nonce"
+	cspNonceSize     = 16
+	cspReportGroup   = "csp-endpoint"
+)
+
+// CSP builds a Content-Security-Policy, directives are written in the order they are first added
+type CSP struct {
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+func NewCSP() *CSP {
+	return &CSP{}
+}
+
+// Add appends sources to a directive, e.g. Add("script-src", CSPSelf, CSPNonce)
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	for i := range p.directives {
+		if p.directives[i].name == directive {
+			p.directives[i].sources = append(p.directives[i].sources, sources...)
+			return p
+		}
+	}
+
+	p.directives = append(p.directives, cspDirective{name: directive, sources: sources})
+	return p
+}
+
+// String renders the policy, nonce replaces CSPNonce sources
+func (p *CSP) String(nonce string) string {
+	var b strings.Builder
+	for i, directive := range p.directives {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(directive.name)
+		for _, source := range directive.sources {
+			b.WriteByte(' ')
+			if source == CSPNonce {
+				b.WriteString("'nonce-" + nonce + "'")
+			} else {
+				b.WriteString(source)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (p *CSP) usesNonce() bool {
+	for _, directive := range p.directives {
+		for _, source := range directive.sources {
+			if source == CSPNonce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type SecurityHeadersConfig struct {
+	// Strict-Transport-Security, only sent over HTTPS, zero disables
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// Header values, empty values are not sent
+	ContentTypeOptions string
+	ReferrerPolicy     string
+	PermissionsPolicy  string
+	FrameOptions       string
+
+	// Content-Security-Policy, nil disables
+	CSP *CSP
+	// Send Content-Security-Policy-Report-Only so violations are reported but not blocked
+	CSPReportOnly bool
+	// Endpoint receiving violation reports, serve it with CSPReportHandler
+	CSPReportURI string
+}
+
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentTypeOptions:    "nosniff",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=()",
+		FrameOptions:          "DENY",
+		CSP: NewCSP().
+			Add("default-src", CSPSelf).
+			Add("script-src", CSPSelf, CSPNonce).
+			Add("style-src", CSPSelf, CSPNonce).
+			Add("object-src", CSPNone).
+			Add("base-uri", CSPSelf).
+			Add("frame-ancestors", CSPNone),
+	}
+}
+
+// SecurityHeadersMiddleware sets the headers before calling next, so streamed responses carry them as well
+func SecurityHeadersMiddleware(config SecurityHeadersConfig) Middleware {
+	var hsts string
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge/time.Second), 10)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	var (
+		csp        *CSP
+		staticCSP  string
+		nonceInCSP bool
+	)
+	if config.CSP != nil {
+		// Copy so later changes to the builder do not race with requests
+		csp = &CSP{directives: make([]cspDirective, len(config.CSP.directives))}
+		for i, directive := range config.CSP.directives {
+			csp.directives[i] = cspDirective{name: directive.name, sources: append([]string(nil), directive.sources...)}
+		}
+		if config.CSPReportURI != "" {
+			csp.Add("report-uri", config.CSPReportURI)
+			csp.Add("report-to", cspReportGroup)
+		}
+
+		nonceInCSP = csp.usesNonce()
+		if !nonceInCSP {
+			staticCSP = csp.String("")
+		}
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			if hsts != "" && IsSecureScheme(req) {
+				res.ReplaceHeaderString("Strict-Transport-Security", hsts)
+			}
+			if config.ContentTypeOptions != "" {
+				res.ReplaceHeaderString("X-Content-Type-Options", config.ContentTypeOptions)
+			}
+			if config.ReferrerPolicy != "" {
+				res.ReplaceHeaderString("Referrer-Policy", config.ReferrerPolicy)
+			}
+			if config.PermissionsPolicy != "" {
+				res.ReplaceHeaderString("Permissions-Policy", config.PermissionsPolicy)
+			}
+			if config.FrameOptions != "" {
+				res.ReplaceHeaderString("X-Frame-Options", config.FrameOptions)
+			}
+
+			if csp != nil {
+				policy := staticCSP
+				if nonceInCSP {
+					nonce, err := newCSPNonce()
+					if err != nil {
+						slog.Error("csp nonce unavailable", "error", err)
+						res.Status = StatusInternalServerError
+						return
+					}
+					req.SetValue(cspNonceValueKey, nonce)
+					policy = csp.String(nonce)
+				}
+
+				res.ReplaceHeaderString(cspHeader, policy)
+				if config.CSPReportURI != "" {
+					res.ReplaceHeaderString("Reporting-Endpoints", cspReportGroup+`="`+config.CSPReportURI+`"`)
+				}
+			}
+
+			next(req, res)
+		}
+	}
+}
+
+// CSPNonceValue returns the nonce of the current request, add it to inline tags as nonce="..."
+func CSPNonceValue(req *Request) string {
+	value, found := req.Value(cspNonceValueKey)
+	if !found {
+		return ""
+	}
+
+	nonce, _ := value.(string)
+	return nonce
+}
+
+func newCSPNonce() (string, error) {
+	raw := make([]byte, cspNonceSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// CSPViolation is the common part of report-uri and Reporting API violation reports
+type CSPViolation struct {
+	DocumentURL        string
+	BlockedURL         string
+	EffectiveDirective string
+	Disposition        string
+	SourceFile         string
+	LineNumber         int
+}
+
+// CSPReportHandler logs violation reports sent to CSPReportURI, mount it as a POST route.
+// A nil logger uses slog.Default
+func CSPReportHandler(logger *slog.Logger) Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(req *Request, res *Response) {
+		violations, err := parseCSPReports(req.Body)
+		if err != nil {
+			res.Status = StatusBadRequest
+			return
+		}
+
+		userAgent, _ := req.Header([]byte("user-agent"))
+		for _, violation := range violations {
+			logger.Warn("csp violation",
+				"document_url", violation.DocumentURL,
+				"blocked_url", violation.BlockedURL,
+				"directive", violation.EffectiveDirective,
+				"disposition", violation.Disposition,
+				"source_file", violation.SourceFile,
+				"line_number", violation.LineNumber,
+				"user_agent", string(userAgent),
+			)
+		}
+
+		res.Status = StatusNoContent
+	}
+}
+
+// parseCSPReports accepts application/csp-report (report-uri) and application/reports+json (report-to) bodies
+func parseCSPReports(body []byte) ([]CSPViolation, error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var reports []struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				BlockedURL         string `json:"blockedURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"sourceFile"`
+				LineNumber         int    `json:"lineNumber"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		violations := make([]CSPViolation, 0, len(reports))
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, CSPViolation(report.Body))
+		}
+		return violations, nil
+	}
+
+	var report struct {
+		Body struct {
+			DocumentURI        string `json:"document-uri"`
+			BlockedURI         string `json:"blocked-uri"`
+			EffectiveDirective string `json:"effective-directive"`
+			ViolatedDirective  string `json:"violated-directive"`
+			Disposition        string `json:"disposition"`
+			SourceFile         string `json:"source-file"`
+			LineNumber         int    `json:"line-number"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+
+	directive := report.Body.EffectiveDirective
+	if directive == "" {
+		directive = report.Body.ViolatedDirective
+	}
+	return []CSPViolation{{
+		DocumentURL:        report.Body.DocumentURI,
+		BlockedURL:         report.Body.BlockedURI,
+		EffectiveDirective: directive,
+		Disposition:        report.Body.Disposition,
+		SourceFile:         report.Body.SourceFile,
+		LineNumber:         report.Body.LineNumber,
+	}}, nil
+}
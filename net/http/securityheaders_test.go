@@ -0,0 +1,112 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	var nonce string
+	handler := SecurityHeadersMiddleware(DefaultSecurityHeadersConfig())(func(req *Request, res *Response) {
+		nonce = CSPNonceValue(req)
+	})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\nX-Forwarded-Proto: https\r\n\r\n")
+	var res Response
+	res.Reset()
+	handler(req, &res)
+
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "DENY",
+	}
+	for name, value := range expected {
+		if got, _ := res.Header([]byte(name)); string(got) != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+
+	if nonce == "" {
+		t.Fatal("Expected a nonce for the handler")
+	}
+	policy, _ := res.Header([]byte("Content-Security-Policy"))
+	if !strings.Contains(string(policy), "script-src 'self' 'nonce-"+nonce+"'") {
+		t.Errorf("Expected nonce in policy, got %q", policy)
+	}
+
+	// Every request gets a fresh nonce
+	previous := nonce
+	res.Reset()
+	handler(parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), &res)
+	if nonce == previous {
+		t.Error("Expected a new nonce per request")
+	}
+	if _, found := res.Header([]byte("Strict-Transport-Security")); found {
+		t.Error("Expected no HSTS over plain HTTP")
+	}
+}
+
+func TestSecurityHeadersReportOnly(t *testing.T) {
+	handler := SecurityHeadersMiddleware(SecurityHeadersConfig{
+		CSP:           NewCSP().Add("default-src", CSPSelf).Add("img-src", "https:"),
+		CSPReportOnly: true,
+		CSPReportURI:  "/csp-reports",
+	})(func(req *Request, res *Response) {
+		if CSPNonceValue(req) != "" {
+			t.Error("Expected no nonce without a nonce source")
+		}
+	})
+
+	var res Response
+	res.Reset()
+	handler(parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), &res)
+
+	if _, found := res.Header([]byte("Content-Security-Policy")); found {
+		t.Error("Expected no enforced policy in report-only mode")
+	}
+	policy, _ := res.Header([]byte("Content-Security-Policy-Report-Only"))
+	if string(policy) != "default-src 'self'; img-src https:; report-uri /csp-reports; report-to csp-endpoint" {
+		t.Errorf("Unexpected policy %q", policy)
+	}
+	if endpoints, _ := res.Header([]byte("Reporting-Endpoints")); string(endpoints) != `csp-endpoint="/csp-reports"` {
+		t.Errorf("Unexpected Reporting-Endpoints %q", endpoints)
+	}
+	if _, found := res.Header([]byte("X-Frame-Options")); found {
+		t.Error("Expected empty config values to be skipped")
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	var logs bytes.Buffer
+	handler := CSPReportHandler(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	tests := []struct {
+		body      string
+		status    uint16
+		directive string
+	}{
+		{`{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"inline","violated-directive":"script-src-elem"}}`, StatusNoContent, "directive=script-src-elem"},
+		{`[{"type":"csp-violation","body":{"documentURL":"https://example.com/","blockedURL":"https://evil.test/x.js","effectiveDirective":"script-src"}}]`, StatusNoContent, "directive=script-src"},
+		{`not json`, StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		logs.Reset()
+		req := parseTestRequest(t, "POST /csp-reports HTTP/1.1\r\nHost: example.com\r\nContent-Length: "+strconv.Itoa(len(tt.body))+"\r\n\r\n"+tt.body)
+		var res Response
+		res.Reset()
+		handler(req, &res)
+
+		if res.Status != tt.status {
+			t.Errorf("Expected status %d for %q, got %d", tt.status, tt.body, res.Status)
+		}
+		if tt.directive != "" && !strings.Contains(logs.String(), tt.directive) {
+			t.Errorf("Expected %q in log, got %q", tt.directive, logs.String())
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -28,6 +29,40 @@ type Server struct {
 	DisableKeepAlive bool
 
 	Logger *slog.Logger
+
+	// Optional instrumentation callbacks, e.g. for OpenTelemetry
+	Hooks ServerHooks
+
+	// Optional Prometheus style metrics, see NewServerMetrics
+	Metrics *ServerMetrics
+
+	// Serve connections from an epoll event loop (Linux only, other platforms use the worker pool).
+	// Idle keep-alive connections then wait without a goroutine and WorkerPoolSize workers, by
+	// default NumCPU*64, serve the connections with a readable request
+	EventLoop bool
+
+	// Number of SO_REUSEPORT listeners opened by ListenAndServe, each with its own accept loop,
+	// e.g. runtime.NumCPU(). Zero opens a single listener
+	ReusePortListeners int
+
+	// How long Shutdown keeps serving after the shutdown hooks ran, e.g. so load balancers notice a
+	// failing readiness probe before the listeners close. Zero closes them right away
+	ShutdownDelay time.Duration
+
+	onShutdown []func()
+
+	// Listeners being served, passed to the new process by Upgrade
+	listeners   []net.Listener
+	listenersMu sync.Mutex
+}
+
+// ServerHooks are called from the connection workers, they must be fast and safe for concurrent use
+type ServerHooks struct {
+	OnConnOpen     func(conn net.Conn)
+	OnConnClose    func(conn net.Conn)
+	OnRequestStart func(req *Request)
+	// Called after the response has been written, res.BytesWritten() holds the body size
+	OnRequestEnd func(req *Request, res *Response, duration time.Duration)
 }
 
 func NewServer(handler Handler) Server {
@@ -43,6 +78,23 @@ func NewServer(handler Handler) Server {
 }
 
 func (s *Server) ListenAndServe(addr string) error {
+	// Listeners passed by the parent process during a binary upgrade
+	inherited, err := inheritedListeners()
+	if err != nil {
+		return err
+	}
+	if len(inherited) > 0 {
+		return s.serveListeners(inherited, upgradeReady(len(inherited)))
+	}
+
+	if s.ReusePortListeners > 0 {
+		lns, err := listenReusePort(addr, s.ReusePortListeners)
+		if err != nil {
+			return err
+		}
+		return s.serveListeners(lns, nil)
+	}
+
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
@@ -60,15 +112,87 @@ func (s *Server) ListenAndServe(addr string) error {
 }
 
 func (s *Server) Serve(ln net.Listener) error {
-	defer func() {
-		if err := ln.Close(); err != nil {
-			s.Logger.Error("ln.Close error", "error", err)
-		}
-	}()
+	return s.serveListeners([]net.Listener{ln}, nil)
+}
+
+// ServeListeners serves several listeners with one worker pool, e.g. those of SystemdListeners
+func (s *Server) ServeListeners(lns []net.Listener) error {
+	return s.serveListeners(lns, nil)
+}
 
-	// Add this defer to signal completion of Serve method
+// serveListeners runs an accept loop per listener feeding the worker pool or the event loop, ready
+// is called once all loops run. The first failing loop closes the other listeners
+func (s *Server) serveListeners(lns []net.Listener, ready func()) error {
+	// Shutdown waits for Serve to return
+	s.Wg.Add(1)
 	defer s.Wg.Done()
 
+	var dispatch func(conn net.Conn)
+	var stop func()
+	if s.EventLoop && eventLoopSupported {
+		loop, err := s.startEventLoop()
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+			return err
+		}
+		dispatch, stop = loop.add, loop.stop
+	} else {
+		dispatch, stop = s.startWorkerPool()
+	}
+
+	s.listenersMu.Lock()
+	s.listeners = lns
+	s.listenersMu.Unlock()
+
+	var (
+		closeOnce sync.Once
+		loops     sync.WaitGroup
+		errs      = make([]error, len(lns))
+	)
+	closeListeners := func() {
+		for _, ln := range lns {
+			if err := ln.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				s.Logger.Error("ln.Close error", "error", err)
+			}
+		}
+	}
+
+	for i, ln := range lns {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+
+			errs[i] = s.acceptLoop(ln, dispatch)
+			if errs[i] != nil {
+				closeOnce.Do(closeListeners)
+			}
+		}()
+	}
+
+	if ready != nil {
+		ready()
+	}
+
+	loops.Wait()
+	closeOnce.Do(closeListeners)
+	stop()
+
+	s.listenersMu.Lock()
+	s.listeners = nil
+	s.listenersMu.Unlock()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startWorkerPool starts WorkerPoolSize goroutines serving a connection each, stop closes their channels
+func (s *Server) startWorkerPool() (dispatch func(conn net.Conn), stop func()) {
 	// Auto-size worker pool if not set - make it power of 2 for faster modulo
 	if s.WorkerPoolSize == 0 {
 		cores := uint32(runtime.NumCPU())
@@ -86,28 +210,66 @@ func (s *Server) Serve(ln net.Listener) error {
 		go s.ServeConn(workerChannels[i])
 	}
 
-	// Use atomic operations for better performance
-	var counter uint32
+	if s.Metrics != nil {
+		s.Metrics.workerChannels.Store(&workerChannels)
+	}
+
+	// Shared counter, the accept loops spread connections over all workers
+	var counter atomic.Uint32
 	mask := s.WorkerPoolSize - 1 // For power-of-2 fast modulo
 
+	dispatch = func(conn net.Conn) {
+		// Fast modulo using bitwise AND (only works with power of 2)
+		idx := (counter.Add(1) - 1) & mask
+
+		// Try multiple workers before giving up
+		for range 3 {
+			select {
+			case workerChannels[idx] <- conn:
+				return
+			default:
+				idx = (idx + 1) & mask // Try next worker
+			}
+		}
+
+		// All workers busy
+		if s.Metrics != nil {
+			s.Metrics.droppedConnections.Inc()
+		}
+		if err := conn.Close(); err != nil {
+			s.Logger.Error("closing connection error", "error", err)
+		}
+	}
+
+	stop = func() {
+		// Close all worker channels to signal shutdown
+		for i := range workerChannels {
+			close(workerChannels[i])
+		}
+	}
+
+	return dispatch, stop
+}
+
+type deadlineListener interface {
+	SetDeadline(t time.Time) error
+}
+
+// acceptLoop dispatches accepted connections until shutdown or an accept error
+func (s *Server) acceptLoop(ln net.Listener, dispatch func(conn net.Conn)) error {
 	for {
 		select {
 		case <-s.ShutdownCh:
 			log.Println("Server shutdown initiated...")
-
-			// Close all worker channels to signal shutdown
-			for i := range workerChannels {
-				close(workerChannels[i])
-			}
-
 			return nil
 		default:
 		}
 
-		// Set a short timeout for Accept during shutdown
-		if tcpLn, ok := ln.(*net.TCPListener); ok {
-			if err := tcpLn.SetDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
-				s.Logger.Error("SetDeadline error", "error", err)
+		// Set a short timeout for Accept during shutdown, TCP and Unix listeners support deadlines
+		deadlineLn, hasDeadline := ln.(deadlineListener)
+		if hasDeadline {
+			if err := deadlineLn.SetDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+				return err
 			}
 		}
 
@@ -117,9 +279,6 @@ func (s *Server) Serve(ln net.Listener) error {
 			select {
 			case <-s.ShutdownCh:
 				log.Println("Server shutdown during Accept")
-				for i := range workerChannels {
-					close(workerChannels[i])
-				}
 				return nil
 			default:
 				// Check if it's a timeout (expected during shutdown)
@@ -130,36 +289,24 @@ func (s *Server) Serve(ln net.Listener) error {
 			}
 		}
 
-		// Reset deadline after successful accept
-		if tcpLn, ok := ln.(*net.TCPListener); ok {
-			if err := tcpLn.SetDeadline(time.Time{}); err != nil {
-				return err
-			}
+		if s.Metrics != nil {
+			s.Metrics.acceptedConnections.Inc()
 		}
 
-		// Fast modulo using bitwise AND (only works with power of 2)
-		idx := counter & mask
-		counter++
-
-		// Try multiple workers before giving up
-		for range 3 {
-			select {
-			case workerChannels[idx] <- conn:
-				goto next_connection
-			default:
-				idx = (idx + 1) & mask // Try next worker
+		// Reset deadline after successful accept
+		if hasDeadline {
+			if err := deadlineLn.SetDeadline(time.Time{}); err != nil {
+				return err
 			}
 		}
 
-		// All workers busy
-		if err := conn.Close(); err != nil {
-			s.Logger.Error("closing connection error", "error", err)
-		}
-
-	next_connection:
+		dispatch(conn)
 	}
 }
 
+// Reduced max requests per connection for faster shutdown
+const maxRequestsPerConnection = 10000
+
 func (s *Server) ServeConn(ch chan net.Conn) {
 	// Signal completion when this worker exits
 	defer s.Wg.Done()
@@ -190,18 +337,33 @@ func (s *Server) ServeConn(ch chan net.Conn) {
 		req.Close = false
 
 		// Don't zero the entire struct - just reset critical fields
-		res.Status = StatusOK
-		res.KeepAlive = true
-		res.Body = nil
-		res.headerCount = 0
-		res.Chunked = false
-		res.writer = nil // Clear writer reference
+		res.Reset()
 
 		s.handleConnection(conn, br, bw, &req, &res)
 	}
 }
 
+// RegisterOnShutdown registers a function to call when Shutdown starts, e.g. to fail readiness probes.
+// Register functions before serving, they are called synchronously in registration order, before
+// the ShutdownDelay.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.onShutdown = append(s.onShutdown, f)
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
+	for _, f := range s.onShutdown {
+		f()
+	}
+
+	if s.ShutdownDelay > 0 {
+		timer := time.NewTimer(s.ShutdownDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
 	// Send shutdown signal to all workers
 	close(s.ShutdownCh)
 
@@ -220,108 +382,155 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 }
 
-func (s *Server) handleConnection(conn net.Conn, br *bufio.Reader, bw *bufio.Writer, req *Request, res *Response) {
-	defer func() {
-		if err := conn.Close(); err != nil {
-			s.Logger.Error("closing connection error", "error", err)
+// tuneTCPConn optimizes TCP connections, including the one under a TLS connection. Other
+// connections, e.g. Unix sockets or in-memory pipes, are left as they are
+func (s *Server) tuneTCPConn(conn net.Conn) {
+	if wrapper, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = wrapper.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	if err := tcpConn.SetNoDelay(true); err != nil { // Disable Nagle's algorithm
+		// Log but don't fail - this is an optimization
+		s.Logger.Error("SetNoDelay error", "error", err)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil { // Enable keep-alive
+		// Log but don't fail - this is an optimization
+		s.Logger.Error("SetKeepAlive error", "error", err)
+	}
+	if err := tcpConn.SetKeepAlivePeriod(3 * time.Minute); err != nil { // Set longer keep-alive period
+		// Log but don't fail - this is an optimization
+		s.Logger.Error("SetKeepAlivePeriod error", "error", err)
+	}
+	if err := tcpConn.SetReadBuffer(128 * 1024); err != nil { // Set larger read buffer for better performance
+		// Log but don't fail - this is an optimization
+		s.Logger.Error("SetReadBuffer error", "error", err)
+	}
+	if err := tcpConn.SetWriteBuffer(128 * 1024); err != nil { // Set larger write buffer for better performance
+		// Log but don't fail - this is an optimization
+		s.Logger.Error("SetWriteBuffer error", "error", err)
+	}
+}
+
+// serveRequest reads and answers one request of a connection, it reports whether the connection stays open
+func (s *Server) serveRequest(br *bufio.Reader, bw *bufio.Writer, req *Request, res *Response, requestCount int) bool {
+	// Drop headers and values of the previous request on this connection
+	req.Reset()
+
+	// Reset response fields individually instead of struct copy
+	res.Reset()
+	// Associate writer with response
+	res.writer = bw
+
+	// Check for shutdown every request
+	select {
+	case <-s.ShutdownCh:
+		// Send connection close response and exit
+		res.KeepAlive = false
+		res.Body = []byte("Server shutting down")
+		if err := res.WriteTo(bw); err != nil {
+			s.Logger.Error("WriteTo error", "error", err)
 		}
-	}()
+		return false
+	default:
+	}
 
-	// Optimize TCP connection once per connection
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		if err := tcpConn.SetNoDelay(true); err != nil { // Disable Nagle's algorithm
-			// Log but don't fail - this is an optimization
-			s.Logger.Error("SetNoDelay error", "error", err)
+	if err := req.Parse(br); err != nil {
+		if err == io.EOF {
+			return false
 		}
-		if err := tcpConn.SetKeepAlive(true); err != nil { // Enable keep-alive
-			// Log but don't fail - this is an optimization
-			s.Logger.Error("SetKeepAlive error", "error", err)
+
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return false
 		}
-		if err := tcpConn.SetKeepAlivePeriod(3 * time.Minute); err != nil { // Set longer keep-alive period
-			// Log but don't fail - this is an optimization
-			s.Logger.Error("SetKeepAlivePeriod error", "error", err)
+
+		if errors.Is(err, syscall.ECONNRESET) {
+			return false
 		}
-		if err := tcpConn.SetReadBuffer(128 * 1024); err != nil { // Set larger read buffer for better performance
-			// Log but don't fail - this is an optimization
-			s.Logger.Error("SetReadBuffer error", "error", err)
+
+		if s.Metrics != nil {
+			s.Metrics.parseErrors.Inc()
 		}
-		if err := tcpConn.SetWriteBuffer(128 * 1024); err != nil { // Set larger write buffer for better performance
-			// Log but don't fail - this is an optimization
-			s.Logger.Error("SetWriteBuffer error", "error", err)
+
+		log.Print("Parse error:", err)
+		return false
+	}
+
+	if s.Metrics != nil {
+		s.Metrics.requests.Inc()
+		if requestCount > 1 {
+			s.Metrics.keepAliveRequests.Inc()
 		}
 	}
 
-	br.Reset(conn)
-	bw.Reset(conn)
+	res.KeepAlive = !req.Close
 
-	// Reduced connection timeout for faster shutdown
-	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
-		s.Logger.Error("SetDeadline error", "error", err)
+	var start time.Time
+	if s.Hooks.OnRequestEnd != nil {
+		start = time.Now()
+	}
+	if s.Hooks.OnRequestStart != nil {
+		s.Hooks.OnRequestStart(req)
 	}
 
-	requestCount := 0
-	// Reduced max requests per connection for faster shutdown
-	maxRequestsPerConnection := 10000
+	// Call handler - it can now use streaming without bw parameter
+	s.Handler(req, res)
 
-	for requestCount < maxRequestsPerConnection {
-		requestCount++
+	// Writes the response, or ends a body the handler streamed without closing it
+	writeErr := res.WriteTo(bw)
 
-		// Reset response fields individually instead of struct copy
-		res.Status = StatusOK
-		res.KeepAlive = true
-		res.Body = nil
-		res.headerCount = 0
-		res.Chunked = false
-		// Associate writer with response
-		res.writer = bw
+	if s.Hooks.OnRequestEnd != nil {
+		s.Hooks.OnRequestEnd(req, res, time.Since(start))
+	}
 
-		// Check for shutdown every request
-		select {
-		case <-s.ShutdownCh:
-			// Send connection close response and exit
-			res.KeepAlive = false
-			res.Body = []byte("Server shutting down")
-			if err := res.WriteTo(bw); err != nil {
-				s.Logger.Error("WriteTo error", "error", err)
-			}
-			return
-		default:
-		}
+	if writeErr != nil {
+		log.Print("WriteTo error:", writeErr)
+		return false
+	}
 
-		if err := req.Parse(br); err != nil {
-			if err == io.EOF {
-				break
-			}
+	// Clear writer reference for safety
+	res.writer = nil
 
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				break
-			}
+	return !req.Close
+}
 
-			if errors.Is(err, syscall.ECONNRESET) {
-				break
-			}
+func (s *Server) handleConnection(conn net.Conn, br *bufio.Reader, bw *bufio.Writer, req *Request, res *Response) {
+	if s.Hooks.OnConnOpen != nil {
+		s.Hooks.OnConnOpen(conn)
+	}
+	if s.Metrics != nil {
+		s.Metrics.activeConnections.Inc()
+		defer s.Metrics.activeConnections.Dec()
+	}
 
-			log.Print("Parse error:", err)
-			break
+	defer func() {
+		if err := conn.Close(); err != nil {
+			s.Logger.Error("closing connection error", "error", err)
+		}
+		if s.Hooks.OnConnClose != nil {
+			s.Hooks.OnConnClose(conn)
 		}
+	}()
 
-		res.KeepAlive = !req.Close
+	s.tuneTCPConn(conn)
 
-		// Call handler - it can now use streaming without bw parameter
-		s.Handler(req, res)
+	br.Reset(conn)
+	bw.Reset(conn)
+	req.RemoteAddr = conn.RemoteAddr()
 
-		// Only write response if not already handled by streaming
-		if !res.Chunked || res.Body != nil {
-			if err := res.WriteTo(bw); err != nil {
-				log.Print("WriteTo error:", err)
-				break
-			}
-		}
+	// Reduced connection timeout for faster shutdown
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		s.Logger.Error("SetDeadline error", "error", err)
+	}
 
-		// Clear writer reference for safety
-		res.writer = nil
+	requestCount := 0
+	for requestCount < maxRequestsPerConnection {
+		requestCount++
 
-		if req.Close {
+		if !s.serveRequest(br, bw, req, res, requestCount) {
 			break
 		}
 
@@ -1,11 +1,15 @@
 package http
 
 import (
+	"bufio"
+	"context"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -14,6 +18,65 @@ import (
 
 var defaultClientsCount = runtime.NumCPU()
 
+// serveTestConn serves raw over an in-memory connection and returns everything written back
+func serveTestConn(t *testing.T, s *Server, raw string) string {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	go func() {
+		_, _ = clientConn.Write([]byte(raw))
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := Request{}
+		res := Response{}
+		s.handleConnection(serverConn, bufio.NewReader(nil), bufio.NewWriter(nil), &req, &res)
+	}()
+
+	out, _ := io.ReadAll(clientConn)
+	<-done
+	return string(out)
+}
+
+func TestServerHooks(t *testing.T) {
+	var opened, closed, started, ended atomic.Int32
+	var status uint16
+	var bytesWritten int
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.Status = StatusCreated
+		res.WithText("hello")
+	})
+	s.Logger = slog.New(slog.DiscardHandler)
+	s.Hooks = ServerHooks{
+		OnConnOpen:     func(conn net.Conn) { opened.Add(1) },
+		OnConnClose:    func(conn net.Conn) { closed.Add(1) },
+		OnRequestStart: func(req *Request) { started.Add(1) },
+		OnRequestEnd: func(req *Request, res *Response, duration time.Duration) {
+			ended.Add(1)
+			status = res.Status
+			bytesWritten = res.BytesWritten()
+		},
+	}
+
+	out := serveTestConn(t, &s, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\nGET / HTTP/1.1\r\nConnection: close\r\n\r\n")
+	if !strings.HasPrefix(out, "HTTP/1.1 201 Created") {
+		t.Fatalf("Unexpected response %q", out)
+	}
+
+	if opened.Load() != 1 || closed.Load() != 1 {
+		t.Errorf("Expected 1 open and close, got %d and %d", opened.Load(), closed.Load())
+	}
+	if started.Load() != 2 || ended.Load() != 2 {
+		t.Errorf("Expected 2 started and ended requests, got %d and %d", started.Load(), ended.Load())
+	}
+	if status != StatusCreated || bytesWritten != 5 {
+		t.Errorf("Expected status 201 with 5 bytes, got %d with %d", status, bytesWritten)
+	}
+}
+
 // func BenchmarkRequestCtxRedirect(b *testing.B) {
 // 	b.RunParallel(func(pb *testing.PB) {
 // 		var ctx RequestCtx
@@ -427,6 +490,132 @@ func verifyRequestsServed(b *testing.B, ch <-chan struct{}) {
 	}
 }
 
+// listenTestServer runs ListenAndServe and waits until s serves its listeners
+func listenTestServer(t testing.TB, s *Server, addr string) []net.Listener {
+	t.Helper()
+
+	go func() {
+		if err := s.ListenAndServe(addr); err != nil {
+			t.Errorf("ListenAndServe failed: %v", err)
+		}
+	}()
+
+	for range 100 {
+		s.listenersMu.Lock()
+		lns := s.listeners
+		s.listenersMu.Unlock()
+		if lns != nil {
+			return lns
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Server did not start listening")
+	return nil
+}
+
+func getTestBody(t *testing.T, addr string) string {
+	t.Helper()
+
+	// Idle keep-alive connections would delay the shutdown of the server
+	client := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestServerReusePort(t *testing.T) {
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("reuseport")
+	})
+	s.WorkerPoolSize = 4
+	s.ReusePortListeners = 4
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	lns := listenTestServer(t, &s, "127.0.0.1:0")
+	if len(lns) != 4 {
+		t.Fatalf("Expected 4 listeners, got %d", len(lns))
+	}
+	for _, ln := range lns[1:] {
+		if ln.Addr().String() != lns[0].Addr().String() {
+			t.Errorf("Expected listeners to share %s, got %s", lns[0].Addr(), ln.Addr())
+		}
+	}
+
+	for range 8 {
+		if body := getTestBody(t, lns[0].Addr().String()); body != "reuseport" {
+			t.Errorf("Expected reuseport, got %q", body)
+		}
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+}
+
+// TestServerUpgradeChild is the new process started by TestServerUpgrade
+func TestServerUpgradeChild(t *testing.T) {
+	if os.Getenv("GRAVEL_TEST_UPGRADE_CHILD") != "1" {
+		t.Skip("started by TestServerUpgrade")
+	}
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("child")
+	})
+	s.WorkerPoolSize = 4
+	if err := s.ListenAndServe("127.0.0.1:1"); err != nil {
+		t.Fatalf("ListenAndServe failed: %v", err)
+	}
+}
+
+func TestServerUpgrade(t *testing.T) {
+	if os.Getenv("GRAVEL_TEST_UPGRADE_CHILD") == "1" {
+		t.Skip("running as the new process")
+	}
+
+	s := NewServer(func(req *Request, res *Response) {
+		res.WithText("parent")
+	})
+	s.WorkerPoolSize = 4
+	s.Logger = slog.New(slog.DiscardHandler)
+
+	lns := listenTestServer(t, &s, "127.0.0.1:0")
+	addr := lns[0].Addr().String()
+	if body := getTestBody(t, addr); body != "parent" {
+		t.Fatalf("Expected parent, got %q", body)
+	}
+
+	// The new process runs only the child test
+	args := os.Args
+	os.Args = []string{os.Args[0], "-test.run=^TestServerUpgradeChild$"}
+	defer func() { os.Args = args }()
+	t.Setenv("GRAVEL_TEST_UPGRADE_CHILD", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	process, err := s.Upgrade(ctx)
+	if err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	defer func() {
+		_ = process.Kill()
+		_, _ = process.Wait()
+	}()
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	// The listener survives the shutdown of the parent
+	if body := getTestBody(t, addr); body != "child" {
+		t.Errorf("Expected child, got %q", body)
+	}
+}
+
 type realServer interface {
 	Serve(ln net.Listener) error
 }
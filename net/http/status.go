@@ -138,3 +138,11 @@ var (
 		StatusNetworkAuthenticationRequired: "Network Authentication Required",
 	}
 )
+
+// StatusText returns the reason phrase of a status code, empty for unknown codes
+func StatusText(code uint16) string {
+	if int(code) >= len(statusMessages) {
+		return ""
+	}
+	return statusMessages[code]
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"time"
+)
+
+type TimeoutConfig struct {
+	Timeout time.Duration
+
+	// Writes the response once the deadline passes, defaults to a plain 503.
+	// Use StatusGatewayTimeout when the handler mostly waits on upstream services
+	ErrorHandler Handler
+}
+
+func DefaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		Timeout: 30 * time.Second,
+		ErrorHandler: func(req *Request, res *Response) {
+			res.Status = StatusServiceUnavailable
+			res.WithText("request timed out")
+		},
+	}
+}
+
+// TimeoutMiddleware runs the handler with a deadline on the request context. The handler runs in its own
+// goroutine on a copy of the request and its own response, which are only merged back when it finishes in
+// time, so a late handler can never touch the response sent to the client. Streaming is not supported
+// underneath this middleware
+func TimeoutMiddleware(config TimeoutConfig) Middleware {
+	defaults := DefaultTimeoutConfig()
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = defaults.ErrorHandler
+	}
+
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			ctx, cancel := context.WithTimeout(req.Context(), config.Timeout)
+			defer cancel()
+
+			// The connection reuses req after we return, the handler may still be running by then
+			handlerReq := req.clone()
+			handlerReq.ctx = ctx
+
+			handlerRes := &Response{}
+			handlerRes.Reset()
+			// Reset assumes keep-alive, the server decided it from the request already
+			handlerRes.KeepAlive = res.KeepAlive
+
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						panicked <- recovered
+					}
+				}()
+
+				next(handlerReq, handlerRes)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				mergeTimeoutResponse(req, handlerReq, res, handlerRes)
+			case recovered := <-panicked:
+				// Re-panic on the connection goroutine so RecoverMiddleware sees it
+				panic(recovered)
+			case <-ctx.Done():
+				route := req.Route()
+				if route == "" {
+					route = string(req.Path)
+				}
+				RequestLogger(req).Warn("request timed out",
+					"method", string(req.Method),
+					"route", route,
+					"timeout", config.Timeout,
+					"cause", context.Cause(ctx),
+				)
+
+				config.ErrorHandler(req, res)
+			}
+		}
+	}
+}
+
+// mergeTimeoutResponse copies what the handler produced onto the request and response of the connection
+func mergeTimeoutResponse(req, handlerReq *Request, res, handlerRes *Response) {
+	for key, value := range handlerReq.values {
+		req.SetValue(key, value)
+	}
+	if handlerReq.route != "" {
+		req.route = handlerReq.route
+	}
+
+	res.Status = handlerRes.Status
+	res.Body = handlerRes.Body
+	res.KeepAlive = handlerRes.KeepAlive
+	for _, t := range handlerRes.trailers {
+		res.SetTrailer(t.name, t.value)
+	}
+
+	// Replace headers set by outer middleware, but keep headers the handler repeated (e.g. Set-Cookie)
+	for i := 0; i < handlerRes.headerCount; i++ {
+		h := handlerRes.header(i)
+		name := h.Name[:h.NameLen]
+		if handlerRes.hasHeaderBefore(i, name) {
+			res.addHeader(name, handlerRes.headerValue(i))
+		} else {
+			res.ReplaceHeader(name, handlerRes.headerValue(i))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := TimeoutMiddleware(TimeoutConfig{Timeout: time.Second})(func(req *Request, res *Response) {
+		req.SetValue("gravel.test", "handler")
+		res.Status = StatusCreated
+		res.SetHeaderString("Content-Type", "application/json")
+		res.SetCookieValue("a", "1")
+		res.SetCookieValue("b", "2")
+		res.Body = []byte(`{}`)
+	})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	var res Response
+	res.Reset()
+	res.SetHeaderString("Content-Type", "text/plain")
+	handler(req, &res)
+
+	if res.Status != StatusCreated || string(res.Body) != `{}` {
+		t.Errorf("Expected handler response, got %d %q", res.Status, res.Body)
+	}
+	if contentType, _ := res.Header([]byte("Content-Type")); string(contentType) != "application/json" {
+		t.Errorf("Expected handler header to replace outer header, got %q", contentType)
+	}
+	if res.headerCount != 3 {
+		t.Errorf("Expected Content-Type and two Set-Cookie headers, got %d headers", res.headerCount)
+	}
+	if value, _ := req.Value("gravel.test"); value != "handler" {
+		t.Errorf("Expected request values to be merged back, got %v", value)
+	}
+}
+
+func TestTimeoutMiddlewareConnectionClose(t *testing.T) {
+	handler := TimeoutMiddleware(TimeoutConfig{Timeout: time.Second})(func(req *Request, res *Response) {
+		res.WithText("bye")
+	})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+	var res Response
+	res.Reset()
+	res.KeepAlive = !req.Close
+	handler(req, &res)
+
+	if res.KeepAlive {
+		t.Errorf("Expected keep-alive to stay disabled for Connection: close")
+	}
+
+	buf := &bytes.Buffer{}
+	bw := bufio.NewWriter(buf)
+	if err := res.WriteTo(bw); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "connection: close\r\n") {
+		t.Errorf("Expected connection: close header, got %q", got)
+	}
+}
+
+func TestTimeoutMiddlewareExceeded(t *testing.T) {
+	cancelled := make(chan error, 1)
+	release := make(chan struct{})
+	defer close(release)
+
+	handler := TimeoutMiddleware(TimeoutConfig{Timeout: 10 * time.Millisecond})(func(req *Request, res *Response) {
+		<-req.Context().Done()
+		cancelled <- req.Context().Err()
+
+		// Writing after the deadline must not reach the client response
+		<-release
+		res.Status = StatusOK
+		res.Body = []byte("late")
+	})
+
+	req := parseTestRequest(t, "GET /slow HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	var res Response
+	res.Reset()
+	handler(req, &res)
+
+	if res.Status != StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", res.Status)
+	}
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected handler context to be cancelled, got %v", err)
+	}
+}
+
+func TestTimeoutMiddlewareErrorHandler(t *testing.T) {
+	handler := TimeoutMiddleware(TimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		ErrorHandler: func(req *Request, res *Response) {
+			res.Status = StatusGatewayTimeout
+		},
+	})(func(req *Request, res *Response) {
+		<-req.Context().Done()
+	})
+
+	var res Response
+	res.Reset()
+	handler(parseTestRequest(t, "GET / HTTP/1.1\r\n\r\n"), &res)
+
+	if res.Status != StatusGatewayTimeout {
+		t.Errorf("Expected 504, got %d", res.Status)
+	}
+}
+
+func TestTimeoutMiddlewarePanic(t *testing.T) {
+	handler := RecoverMiddleware()(TimeoutMiddleware(TimeoutConfig{Timeout: time.Second})(func(req *Request, res *Response) {
+		panic("boom")
+	}))
+
+	var res Response
+	res.Reset()
+	handler(parseTestRequest(t, "GET / HTTP/1.1\r\n\r\n"), &res)
+
+	if res.Status != StatusInternalServerError {
+		t.Errorf("Expected panic to reach RecoverMiddleware, got %d", res.Status)
+	}
+}
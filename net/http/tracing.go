@@ -0,0 +1,214 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+
+	traceFlagSampled = 0x01
+)
+
+var ErrInvalidTraceParent = errors.New("tracing: invalid traceparent")
+
+// TraceContext is the W3C trace context (https://www.w3.org/TR/trace-context/) of a request
+type TraceContext struct {
+	TraceID  [16]byte
+	SpanID   [8]byte // Span of this server
+	ParentID [8]byte // Span of the caller, zero when the trace started here
+	Flags    byte
+	State    string
+}
+
+type traceContextKey struct{}
+
+// ParseTraceParent parses a version 00 traceparent header, e.g. 00-<trace-id>-<parent-id>-01
+func ParseTraceParent(value string) (TraceContext, error) {
+	var tc TraceContext
+
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return tc, ErrInvalidTraceParent
+	}
+	// Future versions may append fields, version ff is forbidden
+	if value[:2] == "ff" || (value[:2] == "00" && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return tc, ErrInvalidTraceParent
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(tc.TraceID[:], []byte(value[3:35])); err != nil {
+		return tc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(tc.ParentID[:], []byte(value[36:52])); err != nil {
+		return tc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return tc, ErrInvalidTraceParent
+	}
+	if tc.TraceID == [16]byte{} || tc.ParentID == [8]byte{} {
+		return tc, ErrInvalidTraceParent
+	}
+
+	tc.Flags = flags[0]
+	return tc, nil
+}
+
+// TraceParent formats the traceparent header to send to the next hop
+func (tc TraceContext) TraceParent() string {
+	var buf [55]byte
+
+	copy(buf[:], "00-")
+	hex.Encode(buf[3:35], tc.TraceID[:])
+	buf[35] = '-'
+	hex.Encode(buf[36:52], tc.SpanID[:])
+	buf[52] = '-'
+	hex.Encode(buf[53:55], []byte{tc.Flags})
+
+	return string(buf[:])
+}
+
+func (tc TraceContext) TraceIDString() string {
+	return hex.EncodeToString(tc.TraceID[:])
+}
+
+func (tc TraceContext) SpanIDString() string {
+	return hex.EncodeToString(tc.SpanID[:])
+}
+
+func (tc TraceContext) IsSampled() bool {
+	return tc.Flags&traceFlagSampled != 0
+}
+
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+type TraceConfig struct {
+	// Starts traces without the sampled flag, continued traces keep the flags of the caller
+	Unsampled bool
+
+	// Starts a span in a tracing library, e.g. OpenTelemetry. The returned context replaces the
+	// request context and end is called once the handler returns.
+	StartSpan func(ctx context.Context, req *Request) (spanCtx context.Context, end func(res *Response))
+}
+
+func DefaultTraceConfig() TraceConfig {
+	return TraceConfig{}
+}
+
+// TraceMiddleware continues the trace from the traceparent/tracestate headers, or starts a new one,
+// and stores it in the request context for handlers and outgoing calls through TraceTransport
+func TraceMiddleware(config TraceConfig) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request, res *Response) {
+			tc, err := traceContextFromRequest(req)
+			if err != nil {
+				// Restart the trace, the caller sent garbage
+				tc = TraceContext{}
+				if !config.Unsampled {
+					tc.Flags = traceFlagSampled
+				}
+				_, _ = rand.Read(tc.TraceID[:])
+			}
+			_, _ = rand.Read(tc.SpanID[:])
+
+			ctx := ContextWithTraceContext(req.Context(), tc)
+
+			var end func(res *Response)
+			if config.StartSpan != nil {
+				ctx, end = config.StartSpan(ctx, req)
+			}
+			req.SetContext(ctx)
+
+			next(req, res)
+
+			if end != nil {
+				end(res)
+			}
+		}
+	}
+}
+
+func traceContextFromRequest(req *Request) (TraceContext, error) {
+	parent, found := req.Header([]byte(TraceParentHeader))
+	if !found {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+
+	tc, err := ParseTraceParent(string(parent))
+	if err != nil {
+		return tc, err
+	}
+
+	if state, found := req.Header([]byte(TraceStateHeader)); found {
+		tc.State = string(state)
+	}
+	return tc, nil
+}
+
+// RequestCarrier exposes request headers to propagators such as OpenTelemetry's TextMapPropagator
+type RequestCarrier struct {
+	Request *Request
+}
+
+func (c RequestCarrier) Get(key string) string {
+	value, _ := c.Request.Header([]byte(key))
+	return string(value)
+}
+
+// Set is a no-op, incoming request headers are read only
+func (c RequestCarrier) Set(key, value string) {}
+
+func (c RequestCarrier) Keys() []string {
+	keys := make([]string, c.Request.headerCount)
+	for i := range keys {
+		h := &c.Request.headers[i]
+		keys[i] = string(h.Name[:h.NameLen])
+	}
+	return keys
+}
+
+// InjectTraceContext writes the trace context of ctx into outgoing headers
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	tc, found := TraceContextFromContext(ctx)
+	if !found {
+		return
+	}
+
+	header.Set(TraceParentHeader, tc.TraceParent())
+	if tc.State != "" {
+		header.Set(TraceStateHeader, tc.State)
+	}
+}
+
+// TraceTransport propagates the trace context of the outgoing request context
+type TraceTransport struct {
+	// Defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *TraceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if _, found := TraceContextFromContext(r.Context()); !found || r.Header.Get(TraceParentHeader) != "" {
+		return base.RoundTrip(r)
+	}
+
+	// RoundTrippers must not modify the original request
+	r = r.Clone(r.Context())
+	InjectTraceContext(r.Context(), r.Header)
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tc, err := ParseTraceParent(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.TraceIDString() != "4bf92f3577b34da6a3ce929d0e0e4736" || !tc.IsSampled() {
+		t.Errorf("Unexpected trace context %+v", tc)
+	}
+
+	tc.SpanID = tc.ParentID
+	if tc.TraceParent() != value {
+		t.Errorf("Expected %s, got %s", value, tc.TraceParent())
+	}
+
+	invalid := []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	}
+	for _, value := range invalid {
+		if _, err := ParseTraceParent(value); err == nil {
+			t.Errorf("Expected %q to be invalid", value)
+		}
+	}
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	var tc TraceContext
+	var ended bool
+	config := DefaultTraceConfig()
+	config.StartSpan = func(ctx context.Context, req *Request) (context.Context, func(res *Response)) {
+		return ctx, func(res *Response) { ended = true }
+	}
+
+	handler := TraceMiddleware(config)(func(req *Request, res *Response) {
+		tc, _ = TraceContextFromContext(req.Context())
+	})
+
+	req := parseTestRequest(t, "GET / HTTP/1.1\r\ntraceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\r\ntracestate: vendor=value\r\n\r\n")
+	res := &Response{}
+	res.Reset()
+	handler(req, res)
+
+	if tc.TraceIDString() != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.State != "vendor=value" {
+		t.Errorf("Expected trace to be continued, got %+v", tc)
+	}
+	if tc.SpanIDString() == "00f067aa0ba902b7" || tc.SpanID == [8]byte{} {
+		t.Errorf("Expected a new span id, got %s", tc.SpanIDString())
+	}
+	if !ended {
+		t.Error("Expected span end to be called")
+	}
+
+	// A new trace is started without valid traceparent
+	req = parseTestRequest(t, "GET / HTTP/1.1\r\ntraceparent: garbage\r\n\r\n")
+	res.Reset()
+	handler(req, res)
+	if tc.TraceID == [16]byte{} || tc.ParentID != [8]byte{} || !tc.IsSampled() {
+		t.Errorf("Expected new sampled root trace, got %+v", tc)
+	}
+
+	if keys := (RequestCarrier{Request: req}).Keys(); len(keys) != 1 || keys[0] != "traceparent" {
+		t.Errorf("Unexpected carrier keys %v", keys)
+	}
+}
+
+func TestTraceMiddlewareSampling(t *testing.T) {
+	tests := []struct {
+		config      TraceConfig
+		traceparent string
+		sampled     bool
+	}{
+		{TraceConfig{}, "", true},
+		{TraceConfig{Unsampled: true}, "", false},
+		{TraceConfig{}, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false},
+		{TraceConfig{Unsampled: true}, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+	}
+
+	for _, test := range tests {
+		var tc TraceContext
+		handler := TraceMiddleware(test.config)(func(req *Request, res *Response) {
+			tc, _ = TraceContextFromContext(req.Context())
+		})
+
+		raw := "GET / HTTP/1.1\r\n"
+		if test.traceparent != "" {
+			raw += "traceparent: " + test.traceparent + "\r\n"
+		}
+		res := &Response{}
+		res.Reset()
+		handler(parseTestRequest(t, raw+"\r\n"), res)
+
+		if tc.IsSampled() != test.sampled {
+			t.Errorf("%+v %q: expected sampled %v, got %v", test.config, test.traceparent, test.sampled, tc.IsSampled())
+		}
+	}
+}
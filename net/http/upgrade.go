@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+)
+
+// Environment variable with the number of listeners passed by Upgrade, their descriptors start at 3
+// and the descriptor after them reports readiness to the parent
+const upgradeListenersEnv = "GRAVEL_UPGRADE_LISTENERS"
+
+// Upgrade starts a new process of the running executable, e.g. after a deploy replaced it, and passes
+// it the listeners so no connection is refused. It returns once the new process serves them through
+// ListenAndServe or ListenAndServeUnix, the caller then shuts this server down to finish its requests,
+// e.g. on SIGUSR2
+//
+//	signals := make(chan os.Signal, 1)
+//	signal.Notify(signals, syscall.SIGUSR2)
+//	for range signals {
+//		if _, err := server.Upgrade(ctx); err != nil {
+//			logger.Error("upgrade failed", "error", err)
+//			continue
+//		}
+//		return server.Shutdown(ctx)
+//	}
+func (s *Server) Upgrade(ctx context.Context) (process *os.Process, err error) {
+	s.listenersMu.Lock()
+	lns := slices.Clone(s.listeners)
+	s.listenersMu.Unlock()
+	if len(lns) == 0 {
+		return nil, errors.New("http: upgrade requires a serving server")
+	}
+
+	files := make([]*os.File, 0, len(lns)+1)
+	closeFiles := func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+		files = nil
+	}
+	defer closeFiles()
+
+	// The socket files stay ours when the upgrade fails
+	var unixLns []*net.UnixListener
+	defer func() {
+		if err != nil {
+			for _, unixLn := range unixLns {
+				unixLn.SetUnlinkOnClose(true)
+			}
+		}
+	}()
+
+	for _, ln := range lns {
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("http: listener %s cannot be passed to a process", ln.Addr())
+		}
+		f, err := filer.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+
+		// The new process serves the socket file, closing this listener must not remove it
+		if unixLn, ok := ln.(*net.UnixListener); ok {
+			unixLn.SetUnlinkOnClose(false)
+			unixLns = append(unixLns, unixLn)
+		}
+	}
+
+	// The new process writes to the pipe once it serves, the read fails when it exits before
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyReader.Close()
+	files = append(files, readyWriter)
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(), upgradeListenersEnv+"="+strconv.Itoa(len(lns)))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// Only the new process keeps the descriptors open
+	closeFiles()
+
+	ready := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		if _, err := readyReader.Read(b[:]); err != nil {
+			ready <- errors.New("http: new process exited before serving")
+			return
+		}
+		ready <- nil
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			_ = cmd.Wait()
+			return nil, err
+		}
+		return cmd.Process, nil
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, ctx.Err()
+	}
+}
+
+// inheritedListeners returns the listeners passed by the Upgrade of the parent process
+func inheritedListeners() ([]net.Listener, error) {
+	value, found := os.LookupEnv(upgradeListenersEnv)
+	if !found {
+		return nil, nil
+	}
+	// Listeners are taken once
+	_ = os.Unsetenv(upgradeListenersEnv)
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("http: invalid %s %q", upgradeListenersEnv, value)
+	}
+
+	return fileListeners(n)
+}
+
+// upgradeReady tells the parent process the n inherited listeners are served
+func upgradeReady(n int) func() {
+	return func() {
+		f := os.NewFile(uintptr(3+n), "upgrade-ready")
+		if f == nil {
+			return
+		}
+		_, _ = f.Write([]byte{1})
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"strings"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+//go:embed docs.html
+var docsHTML string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsHTML))
+
+// Handler serves the document as JSON, it is marshalled once
+func Handler(doc *Document) http.Handler {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: document cannot be marshalled: " + err.Error())
+	}
+
+	return func(req *http.Request, res *http.Response) {
+		res.Body = body
+		res.SetHeaderString("content-type", "application/json")
+	}
+}
+
+// SwaggerUIVersion is the swagger-ui-dist release the docs page loads by default
+const SwaggerUIVersion = "5.17.14"
+
+type DocsConfig struct {
+	Title string
+	// URL of the document, e.g. served by Handler
+	SpecURL string
+
+	// Base URL of the swagger-ui-dist files, e.g. "/docs/assets" for a copy served by the
+	// application itself. Defaults to SwaggerUIVersion on unpkg.com
+	AssetsURL string
+	// Subresource integrity of swagger-ui.css and swagger-ui-bundle.js (e.g. "sha384-..."),
+	// set them when AssetsURL points to a third party
+	StylesheetIntegrity string
+	ScriptIntegrity     string
+}
+
+func DefaultDocsConfig() DocsConfig {
+	return DocsConfig{
+		Title:     "API",
+		SpecURL:   "/openapi.json",
+		AssetsURL: "https://unpkg.com/swagger-ui-dist@" + SwaggerUIVersion,
+	}
+}
+
+// DocsHandler serves a Swagger UI page for the document at specURL with the default assets
+func DocsHandler(title, specURL string) http.Handler {
+	config := DefaultDocsConfig()
+	config.Title = title
+	config.SpecURL = specURL
+	return DocsHandlerWithConfig(config)
+}
+
+// DocsHandlerWithConfig serves a Swagger UI page, the stylesheet and scripts carry the nonce of
+// SecurityHeadersMiddleware when one is set
+func DocsHandlerWithConfig(config DocsConfig) http.Handler {
+	defaults := DefaultDocsConfig()
+	if config.Title == "" {
+		config.Title = defaults.Title
+	}
+	if config.SpecURL == "" {
+		config.SpecURL = defaults.SpecURL
+	}
+	if config.AssetsURL == "" {
+		config.AssetsURL = defaults.AssetsURL
+	}
+	config.AssetsURL = strings.TrimSuffix(config.AssetsURL, "/")
+
+	return func(req *http.Request, res *http.Response) {
+		var buf bytes.Buffer
+		err := docsTemplate.Execute(&buf, struct {
+			DocsConfig
+			Nonce string
+		}{config, http.CSPNonceValue(req)})
+		if err != nil {
+			res.Status = http.StatusInternalServerError
+			return
+		}
+
+		res.WithHTML(buf.String())
+	}
+}
@@ -0,0 +1,301 @@
+// Package openapi generates an OpenAPI 3.1 document from the routes of an http.Router.
+//
+// Routes are described with RouteDoc, request and response types are reflected following the rules of
+// encoding/json. Struct tags of http.Bind become parameters, validate rules become schema constraints and
+// a doc tag becomes the description of a field.
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components,omitzero"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme, e.g. {Type: "http", Scheme: "bearer", BearerFormat: "JWT"} or {Type: "apiKey", In: "header", Name: "X-API-Key"}
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Config struct {
+	Info            Info
+	Servers         []Server
+	SecuritySchemes map[string]SecurityScheme
+
+	// Leave out routes without a RouteDoc, e.g. the metrics and docs handlers
+	DocumentedOnly bool
+}
+
+// Methods of an OpenAPI path item, other methods (CONNECT) cannot be documented
+var operationMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+func Generate(config Config, routes []http.Route) *Document {
+	reflector := NewReflector()
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    config.Info,
+		Servers: config.Servers,
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			SecuritySchemes: config.SecuritySchemes,
+		},
+	}
+
+	for _, route := range routes {
+		if route.Doc == nil && config.DocumentedOnly {
+			continue
+		}
+
+		path, pathParams := openAPIPath(route.Path)
+		methods := slices.DeleteFunc(slices.Clone(route.Methods), func(method string) bool {
+			return !slices.Contains(operationMethods, method)
+		})
+
+		for _, method := range methods {
+			operation := newOperation(reflector, route.Doc, pathParams)
+			if operation.OperationID != "" && len(methods) > 1 {
+				operation.OperationID += "_" + strings.ToLower(method)
+			}
+
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]Operation)
+			}
+			doc.Paths[path][strings.ToLower(method)] = operation
+		}
+	}
+
+	if len(reflector.Schemas) > 0 {
+		doc.Components.Schemas = reflector.Schemas
+	}
+	return doc
+}
+
+func newOperation(reflector *Reflector, routeDoc *http.RouteDoc, pathParams []pathParam) Operation {
+	operation := Operation{Responses: make(map[string]Response)}
+	if routeDoc == nil {
+		routeDoc = &http.RouteDoc{}
+	}
+
+	operation.OperationID = routeDoc.OperationID
+	operation.Summary = routeDoc.Summary
+	operation.Description = routeDoc.Description
+	operation.Tags = routeDoc.Tags
+	operation.Deprecated = routeDoc.Deprecated
+
+	if routeDoc.Request != nil {
+		operation.Parameters, operation.RequestBody = requestSchema(reflector, reflect.TypeOf(routeDoc.Request))
+	}
+
+	// Path segments the request type does not describe are typed by their constraint
+	for _, param := range pathParams {
+		if !slices.ContainsFunc(operation.Parameters, func(p Parameter) bool { return p.In == "path" && p.Name == param.name }) {
+			operation.Parameters = append(operation.Parameters, Parameter{Name: param.name, In: "path", Required: true, Schema: param.schema()})
+		}
+	}
+
+	for status, body := range routeDoc.Responses {
+		response := Response{Description: http.StatusText(status)}
+		if body != nil {
+			response.Content = map[string]MediaType{"application/json": {Schema: reflector.Reflect(reflect.TypeOf(body))}}
+		}
+		operation.Responses[strconv.Itoa(int(status))] = response
+	}
+	if len(operation.Responses) == 0 {
+		operation.Responses["200"] = Response{Description: http.StatusText(http.StatusOK)}
+	}
+
+	// Every listed scheme is an alternative
+	for _, scheme := range routeDoc.Security {
+		operation.Security = append(operation.Security, map[string][]string{scheme: {}})
+	}
+
+	return operation
+}
+
+// requestSchema splits a Bind target into parameters and the form or JSON body
+func requestSchema(reflector *Reflector, t reflect.Type) ([]Parameter, *RequestBody) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: reflector.Reflect(t)}}}
+	}
+
+	var (
+		parameters []Parameter
+		form       = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		bound      bool
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+
+		for _, source := range []string{"path", "query", "header"} {
+			name, found := field.Tag.Lookup(source)
+			if !found {
+				continue
+			}
+			bound = true
+
+			schema := reflector.Reflect(field.Type)
+			applyValidationRules(schema, rules)
+			parameters = append(parameters, Parameter{
+				Name:        name,
+				In:          source,
+				Description: field.Tag.Get("doc"),
+				Required:    source == "path" || slices.Contains(rules, "required"),
+				Schema:      schema,
+			})
+		}
+
+		if name, found := field.Tag.Lookup("form"); found {
+			bound = true
+
+			schema := reflector.Reflect(field.Type)
+			applyValidationRules(schema, rules)
+			form.Properties[name] = schema
+			if slices.Contains(rules, "required") {
+				form.Required = append(form.Required, name)
+			}
+		}
+	}
+
+	if len(form.Properties) > 0 {
+		return parameters, &RequestBody{Required: true, Content: map[string]MediaType{"application/x-www-form-urlencoded": {Schema: form}}}
+	}
+
+	// Without bound fields the type is a plain body which can be shared as a component
+	var body *Schema
+	if !bound {
+		body = reflector.Reflect(t)
+	} else {
+		body = reflector.objectSchema(t, isBoundField)
+		if len(body.Properties) == 0 {
+			return parameters, nil
+		}
+	}
+	return parameters, &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: body}}}
+}
+
+func isBoundField(field reflect.StructField) bool {
+	for _, source := range []string{"path", "query", "header", "form"} {
+		if _, found := field.Tag.Lookup(source); found {
+			return true
+		}
+	}
+	return false
+}
+
+type pathParam struct {
+	name       string
+	constraint string
+}
+
+func (param pathParam) schema() *Schema {
+	switch param.constraint {
+	case "":
+		return &Schema{Type: "string"}
+	case "int":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "uuid":
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+	return &Schema{Type: "string", Pattern: "^(?:" + param.constraint + ")$"}
+}
+
+// openAPIPath rewrites :name, {name:constraint} and * segments to {name} and lists the path parameters
+func openAPIPath(routePath string) (string, []pathParam) {
+	segments := strings.Split(routePath, "/")
+
+	var params []pathParam
+	for i, segment := range segments {
+		var param pathParam
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			param.name = segment[1:]
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			param.name, param.constraint, _ = strings.Cut(segment[1:len(segment)-1], ":")
+		case segment == "*" && i == len(segments)-1:
+			param.name = "*"
+		default:
+			continue
+		}
+
+		segments[i] = "{" + param.name + "}"
+		params = append(params, param)
+	}
+
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,215 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freekieb7/gravel/net/http"
+)
+
+type testAudit struct {
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+}
+
+type testUser struct {
+	testAudit
+	ID       int64          `json:"id,string"`
+	Name     string         `json:"name" validate:"required,min:2,max:64" doc:"Display name"`
+	Email    *string        `json:"email,omitempty"`
+	Avatar   []byte         `json:"avatar"`
+	Tags     []string       `json:"tags" validate:"max:5"`
+	Labels   map[string]int `json:"labels"`
+	Manager  *testUser      `json:"manager"`
+	Password string         `json:"-"`
+	internal string
+	Raw      json.RawMessage `json:"raw"`
+	Extra    map[string]any  `json:",omitempty"`
+}
+
+type testCreateUser struct {
+	Org    string `path:"org"`
+	DryRun bool   `query:"dry_run"`
+	Token  string `header:"X-Invite-Token" validate:"required"`
+	Name   string `json:"name" validate:"required,max:64"`
+}
+
+func TestReflectStruct(t *testing.T) {
+	reflector := NewReflector()
+	schema := reflector.Reflect(reflect.TypeFor[*testUser]())
+
+	if schema.Ref != "#/components/schemas/testUser" {
+		t.Fatalf("Expected a component reference, got %+v", schema)
+	}
+
+	user := reflector.Schemas["testUser"]
+	if user == nil || user.Type != "object" {
+		t.Fatalf("Expected object component, got %+v", user)
+	}
+
+	for _, name := range []string{"Password", "internal", "-"} {
+		if _, found := user.Properties[name]; found {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+
+	if id := user.Properties["id"]; id.Type != "string" {
+		t.Errorf("Expected id with ,string option as string, got %+v", id)
+	}
+	if name := user.Properties["name"]; name.Description != "Display name" || *name.MinLength != 2 || *name.MaxLength != 64 {
+		t.Errorf("Expected outer name with doc and length, got %+v", name)
+	}
+	if created := user.Properties["created_at"]; created.Format != "date-time" {
+		t.Errorf("Expected promoted date-time field, got %+v", created)
+	}
+	if avatar := user.Properties["avatar"]; avatar.Type != "string" || avatar.Format != "byte" {
+		t.Errorf("Expected base64 string, got %+v", avatar)
+	}
+	if tags := user.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" || *tags.MaxItems != 5 {
+		t.Errorf("Expected string array with max items, got %+v", tags)
+	}
+	if labels := user.Properties["labels"]; labels.AdditionalProperties.Format != "int64" {
+		t.Errorf("Expected map of integers, got %+v", labels)
+	}
+	if manager := user.Properties["manager"]; manager.Ref != "#/components/schemas/testUser" {
+		t.Errorf("Expected recursive reference, got %+v", manager)
+	}
+	if raw := user.Properties["raw"]; raw.Type != "" {
+		t.Errorf("Expected any value for raw JSON, got %+v", raw)
+	}
+	if _, found := user.Properties["Extra"]; !found {
+		t.Errorf("Expected untagged name Extra")
+	}
+	if !slices.Equal(user.Required, []string{"name"}) {
+		t.Errorf("Expected only name required, got %v", user.Required)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	router := http.NewRouter()
+	router.POST("/orgs/:org/users", nil).Doc(http.RouteDoc{
+		OperationID: "createUser",
+		Summary:     "Create a user",
+		Tags:        []string{"users"},
+		Request:     testCreateUser{},
+		Responses:   map[uint16]any{http.StatusCreated: testUser{}, http.StatusUnprocessableEntity: nil},
+		Security:    []string{"bearer", "apiKey"},
+	})
+	router.Any([]string{"GET", "HEAD"}, "/files/*", nil).Doc(http.RouteDoc{OperationID: "getFile"})
+	router.GET("/metrics", nil)
+	router.GET("/orders/{id:int}/items/{sku:[A-Z]+}", nil).Doc(http.RouteDoc{OperationID: "getOrderItem"})
+
+	doc := Generate(Config{
+		Info:            Info{Title: "Test", Version: "1.0.0"},
+		SecuritySchemes: map[string]SecurityScheme{"bearer": {Type: "http", Scheme: "bearer"}},
+		DocumentedOnly:  true,
+	}, router.Routes)
+
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected version 3.1.0, got %s", doc.OpenAPI)
+	}
+	if _, found := doc.Paths["/metrics"]; found {
+		t.Errorf("Expected undocumented route to be left out")
+	}
+
+	create, found := doc.Paths["/orgs/{org}/users"]["post"]
+	if !found {
+		t.Fatalf("Expected /orgs/{org}/users, got %v", doc.Paths)
+	}
+	if create.OperationID != "createUser" || create.Summary != "Create a user" {
+		t.Errorf("Unexpected operation %+v", create)
+	}
+
+	expected := map[string]string{"org": "path", "dry_run": "query", "X-Invite-Token": "header"}
+	if len(create.Parameters) != len(expected) {
+		t.Errorf("Expected %d parameters, got %+v", len(expected), create.Parameters)
+	}
+	for _, parameter := range create.Parameters {
+		if expected[parameter.Name] != parameter.In {
+			t.Errorf("Unexpected parameter %+v", parameter)
+		}
+		if parameter.Name == "dry_run" && (parameter.Required || parameter.Schema.Type != "boolean") {
+			t.Errorf("Expected optional boolean dry_run, got %+v", parameter)
+		}
+		if parameter.In != "query" && !parameter.Required {
+			t.Errorf("Expected %s to be required", parameter.Name)
+		}
+	}
+
+	body := create.RequestBody.Content["application/json"].Schema
+	if len(body.Properties) != 1 || body.Properties["name"] == nil || !slices.Equal(body.Required, []string{"name"}) {
+		t.Errorf("Expected body with only name, got %+v", body)
+	}
+
+	if created := create.Responses["201"]; created.Description != "Created" || created.Content["application/json"].Schema.Ref == "" {
+		t.Errorf("Unexpected 201 response %+v", created)
+	}
+	if invalid := create.Responses["422"]; invalid.Content != nil {
+		t.Errorf("Expected 422 without content, got %+v", invalid)
+	}
+	if len(create.Security) != 2 {
+		t.Errorf("Expected alternative security requirements, got %v", create.Security)
+	}
+	if doc.Components.Schemas["testUser"] == nil {
+		t.Errorf("Expected testUser component")
+	}
+
+	item := doc.Paths["/orders/{id}/items/{sku}"]["get"]
+	if len(item.Parameters) != 2 || item.Parameters[0].Schema.Type != "integer" || item.Parameters[1].Schema.Pattern != "^(?:[A-Z]+)$" {
+		t.Errorf("Expected constrained parameters, got %+v", item.Parameters)
+	}
+
+	for _, method := range []string{"get", "head"} {
+		operation := doc.Paths["/files/{*}"][method]
+		if operation.OperationID != "getFile_"+method {
+			t.Errorf("Expected method suffix, got %q", operation.OperationID)
+		}
+		if len(operation.Parameters) != 1 || operation.Parameters[0].Name != "*" || operation.Responses["200"].Description != "OK" {
+			t.Errorf("Expected wildcard parameter and default response, got %+v", operation)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	doc := Generate(Config{Info: Info{Title: "Test", Version: "1.0.0"}}, nil)
+
+	var req http.Request
+	var res http.Response
+	res.Reset()
+	Handler(doc)(&req, &res)
+
+	var decoded map[string]any
+	if err := json.Unmarshal(res.Body, &decoded); err != nil || decoded["openapi"] != "3.1.0" {
+		t.Errorf("Expected OpenAPI JSON, got %s", res.Body)
+	}
+	if _, found := decoded["components"]; found {
+		t.Errorf("Expected empty components to be omitted, got %s", res.Body)
+	}
+
+	res.Reset()
+	DocsHandler("Test <API>", "/openapi.json")(&req, &res)
+	if !strings.Contains(string(res.Body), `url: "/openapi.json"`) || !strings.Contains(string(res.Body), "Test &lt;API&gt;") {
+		t.Errorf("Expected escaped docs page, got %s", res.Body)
+	}
+	if !strings.Contains(string(res.Body), "swagger-ui-dist@"+SwaggerUIVersion+"/swagger-ui.css") {
+		t.Errorf("Expected pinned assets, got %s", res.Body)
+	}
+
+	// Stylesheet and scripts pass the nonce based policy of SecurityHeadersMiddleware
+	docs := DocsHandlerWithConfig(DocsConfig{AssetsURL: "/docs/assets/", StylesheetIntegrity: "sha384-css", ScriptIntegrity: "sha384-js"})
+	res.Reset()
+	http.SecurityHeadersMiddleware(http.DefaultSecurityHeadersConfig())(docs)(&req, &res)
+
+	body := string(res.Body)
+	if http.CSPNonceValue(&req) == "" || strings.Count(body, ` nonce="`) != 3 {
+		t.Errorf("Expected the nonce on the stylesheet and both scripts, got %s", body)
+	}
+	if !strings.Contains(body, `href="/docs/assets/swagger-ui.css" integrity="sha384-css" crossorigin="anonymous"`) ||
+		!strings.Contains(body, `src="/docs/assets/swagger-ui-bundle.js" integrity="sha384-js" crossorigin="anonymous"`) {
+		t.Errorf("Expected self hosted assets with integrity, got %s", body)
+	}
+}
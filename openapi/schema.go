@@ -0,0 +1,269 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema 2020-12 produced by the Reflector
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+var (
+	timeType           = reflect.TypeFor[time.Time]()
+	jsonMarshalerType  = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType  = reflect.TypeFor[encoding.TextMarshaler]()
+	jsonRawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+const componentSchemaPath = "#/components/schemas/"
+
+// Reflector converts Go types to schemas the way encoding/json marshals them. Named struct types
+// are collected in Schemas and referenced, which also handles recursive types
+type Reflector struct {
+	Schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func NewReflector() *Reflector {
+	return &Reflector{
+		Schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (r *Reflector) Reflect(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == jsonRawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom JSON, the shape is unknown
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes []byte as base64
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.Reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.Reflect(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.objectSchema(t, nil)
+		}
+		return &Schema{Ref: componentSchemaPath + r.component(t)}
+	}
+
+	// Interfaces and other types accept any value
+	return &Schema{}
+}
+
+// component registers a named struct type under a unique name
+func (r *Reflector) component(t reflect.Type) string {
+	if name, found := r.names[t]; found {
+		return name
+	}
+
+	name := componentName(t)
+	for i := 2; r.Schemas[name] != nil; i++ {
+		name = componentName(t) + strconv.Itoa(i)
+	}
+
+	// Register before reflecting the fields, so recursive references resolve
+	r.names[t] = name
+	r.Schemas[name] = &Schema{}
+	*r.Schemas[name] = *r.objectSchema(t, nil)
+	return name
+}
+
+func componentName(t reflect.Type) string {
+	// Generic instantiations are named like Page[example.com/pkg.User]
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, t.Name())
+}
+
+// objectSchema reflects the fields encoding/json marshals, skip excludes fields (e.g. bound parameters)
+func (r *Reflector) objectSchema(t reflect.Type, skip func(field reflect.StructField) bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for _, field := range jsonFields(t) {
+		if skip != nil && skip(field.StructField) {
+			continue
+		}
+
+		var property *Schema
+		if field.asString {
+			property = &Schema{Type: "string"}
+		} else {
+			property = r.Reflect(field.Type)
+		}
+		if description := field.Tag.Get("doc"); description != "" && property.Ref == "" {
+			property.Description = description
+		}
+
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		applyValidationRules(property, rules)
+		if slices.Contains(rules, "required") {
+			schema.Required = append(schema.Required, field.name)
+		}
+
+		schema.Properties[field.name] = property
+	}
+
+	return schema
+}
+
+type jsonField struct {
+	reflect.StructField
+	name     string
+	asString bool
+}
+
+// jsonFields lists the fields encoding/json marshals, embedded struct fields are promoted unless shadowed
+func jsonFields(t reflect.Type) []jsonField {
+	var (
+		fields   []jsonField
+		embedded []reflect.StructField
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Pointer {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				embedded = append(embedded, field)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		asString := false
+		for option := range strings.SplitSeq(options, ",") {
+			if option == "string" {
+				switch field.Type.Kind() {
+				case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.String:
+					asString = true
+				}
+			}
+		}
+
+		fields = append(fields, jsonField{StructField: field, name: name, asString: asString})
+	}
+
+	// Fields of the outer struct win over promoted fields with the same name
+	for _, field := range embedded {
+		embeddedType := field.Type
+		if embeddedType.Kind() == reflect.Pointer {
+			embeddedType = embeddedType.Elem()
+		}
+
+		for _, promoted := range jsonFields(embeddedType) {
+			if !slices.ContainsFunc(fields, func(f jsonField) bool { return f.name == promoted.name }) {
+				fields = append(fields, promoted)
+			}
+		}
+	}
+
+	return fields
+}
+
+// applyValidationRules documents min:N and max:N rules of the validation package
+func applyValidationRules(schema *Schema, rules []string) {
+	for _, rule := range rules {
+		name, argument, found := strings.Cut(rule, ":")
+		if !found || (name != "min" && name != "max") {
+			continue
+		}
+		n, err := strconv.Atoi(argument)
+		if err != nil {
+			continue
+		}
+		limit := float64(n)
+
+		switch schema.Type {
+		case "string":
+			if name == "min" {
+				schema.MinLength = &n
+			} else {
+				schema.MaxLength = &n
+			}
+		case "integer", "number":
+			if name == "min" {
+				schema.Minimum = &limit
+			} else {
+				schema.Maximum = &limit
+			}
+		case "array":
+			if name == "min" {
+				schema.MinItems = &n
+			} else {
+				schema.MaxItems = &n
+			}
+		}
+	}
+}
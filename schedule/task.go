@@ -2,16 +2,21 @@ package schedule
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var ErrSchedulerNotRunning = errors.New("scheduler: not running")
+
 type Scheduler struct {
-	jobs []*Job
-	mu   sync.RWMutex
+	jobs    []*Job
+	mu      sync.RWMutex
+	running atomic.Bool
 }
 
 func NewScheduler() *Scheduler {
@@ -132,6 +137,9 @@ func (scheduler *Scheduler) Run(ctx context.Context) error {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
+	scheduler.running.Store(true)
+	defer scheduler.running.Store(false)
+
 	for {
 		select {
 		case <-ticker.C:
@@ -154,6 +162,19 @@ func (scheduler *Scheduler) Run(ctx context.Context) error {
 	}
 }
 
+// IsRunning reports whether Run is active
+func (scheduler *Scheduler) IsRunning() bool {
+	return scheduler.running.Load()
+}
+
+// HealthCheck fails when the scheduler is not running
+func (scheduler *Scheduler) HealthCheck(ctx context.Context) error {
+	if !scheduler.IsRunning() {
+		return ErrSchedulerNotRunning
+	}
+	return nil
+}
+
 func (job *Job) shouldExecute(now time.Time) bool {
 	job.mu.RLock()
 	defer job.mu.RUnlock()
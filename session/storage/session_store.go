@@ -1,6 +1,10 @@
 package storage
 
-import "github.com/freekieb7/gravel/session"
+import (
+	"context"
+
+	"github.com/freekieb7/gravel/session"
+)
 
 type SessionStore interface {
 	Close() error
@@ -9,3 +13,20 @@ type SessionStore interface {
 	Save(session session.Session) error
 	Delete(id string) error
 }
+
+// Pinger is implemented by stores backed by an external service
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthCheck returns a check reporting whether the store is reachable
+func HealthCheck(store SessionStore) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if pinger, ok := store.(Pinger); ok {
+			return pinger.Ping(ctx)
+		}
+
+		// Stores without Ping are in-process and always reachable
+		return nil
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"strings"
+	"time"
 )
 
 var (
@@ -27,6 +28,29 @@ func NewV4() UUID {
 	return uuid
 }
 
+// NewV7 returns a time-ordered UUID (RFC 9562), prefixed by the unix timestamp in milliseconds
+func NewV7() UUID {
+	var uuid UUID
+
+	_, err := rand.Read(uuid[6:])
+	if err != nil {
+		return uuid
+	}
+
+	ms := uint64(time.Now().UnixMilli())
+	uuid[0] = byte(ms >> 40)
+	uuid[1] = byte(ms >> 32)
+	uuid[2] = byte(ms >> 24)
+	uuid[3] = byte(ms >> 16)
+	uuid[4] = byte(ms >> 8)
+	uuid[5] = byte(ms)
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x70 // Version 7
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // Variant is 10
+
+	return uuid
+}
+
 func Parse(s string) (UUID, error) {
 	var uuid UUID
 
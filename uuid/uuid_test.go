@@ -2,6 +2,7 @@ package uuid_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/freekieb7/gravel/uuid"
 )
@@ -20,6 +21,20 @@ func TestUUIDConversion(t *testing.T) {
 	}
 }
 
+func TestUUIDV7(t *testing.T) {
+	first := uuid.NewV7()
+	time.Sleep(2 * time.Millisecond)
+	second := uuid.NewV7()
+
+	if first.Version() != 7 {
+		t.Errorf("expected version 7, got %d", first.Version())
+	}
+
+	if first.String() >= second.String() {
+		t.Errorf("expected %s to sort before %s", first, second)
+	}
+}
+
 func BenchmarkUUIDToString(b *testing.B) {
 	for b.Loop() {
 		id := uuid.NewV4()
@@ -42,6 +42,14 @@ func init() {
 							return err
 						}
 					}
+				case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+					reflect.Float32, reflect.Float64, reflect.Pointer, reflect.Struct:
+					{
+						if v.IsZero() {
+							return err
+						}
+					}
 				default:
 					{
 						return fmt.Errorf("unknown type %T", fieldValue)